@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -17,28 +18,86 @@ func main() {
 		Short: "A directory fuzzer written in Go",
 		Long:  `dirfuzz is a tool for recursively scanning web directories and files for hidden content and misconfigurations.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := options.Validate(); err != nil {
+			scanner, err := fuzz.NewScanner(options)
+			if err != nil {
 				log.Fatal(err)
 			}
-			scanner := fuzz.NewScanner(options)
-			results, err := scanner.Scan()
+			out, err := output.New(options.OutputFormat, options.OutputFile)
 			if err != nil {
 				log.Fatal(err)
 			}
-			if err := output.WriteResults(options.OutputFormat, results, options.OutputFile); err != nil {
+			scanner.SetOutput(out)
+			_, err = scanner.Scan()
+			if cerr := out.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
 				log.Fatal(err)
 			}
+			output.PrintSummary(scanner.Summary())
 		},
 	}
 
-	rootCmd.Flags().StringVarP(&options.TargetURL, "url", "u", "", "The target URL to scan")
-	rootCmd.Flags().StringVarP(&options.WordlistFile, "wordlist", "w", "", "The path to the wordlist file")
+	rootCmd.Flags().StringVarP(&options.TargetURL, "url", "u", "", "The target URL to scan, with FUZZ where payloads go (default: appended as a path)")
+	rootCmd.Flags().StringVarP(&options.Method, "method", "X", "GET", "The request method")
+	rootCmd.Flags().StringArrayVarP(&options.Headers, "header", "H", nil, "A request header as 'Name: value' (repeatable)")
+	rootCmd.Flags().StringVarP(&options.Cookie, "cookie", "b", "", "The Cookie header to send")
+	rootCmd.Flags().StringVar(&options.Data, "data", "", "The request body")
+	rootCmd.Flags().StringVar(&options.RequestFile, "request", "", "A raw HTTP request file to use as the template, with FUZZ anywhere in it (-u then only sets where it is sent)")
+	rootCmd.Flags().StringVar(&options.RequestScheme, "request-scheme", "https", "The scheme to send the raw request with when -u is not given (http, https)")
+	rootCmd.Flags().BoolVar(&options.CookieJar, "cookie-jar", false, "Keep the cookies the target sets and send them with later requests")
+	rootCmd.Flags().StringVar(&options.LoginRequestFile, "login-request", "", "A raw HTTP request file that logs in before the scan and when the session expires (enables --cookie-jar)")
+	rootCmd.Flags().StringVar(&options.LoginExtract, "login-extract", "", "How to take a token from the login response: 'regex:PATTERN' (first group) or 'json:PATH', e.g. 'json:$.data.token'")
+	rootCmd.Flags().StringVar(&options.LoginInject, "login-inject", "", "How to send the login token: a header such as 'X-Auth: {token}' or 'cookie:NAME' (default 'Authorization: Bearer {token}')")
+	rootCmd.Flags().StringVar(&options.Auth, "auth", "", "HTTP authentication as 'basic:USER:PASS', 'digest:USER:PASS', 'bearer:TOKEN' or 'ntlm:[DOMAIN\\]USER:PASS', for requests without an Authorization header of their own")
+	rootCmd.Flags().StringVar(&options.LoggedOut, "logged-out", "", "A rule matching responses of an expired session, e.g. 'status:401 || header:Location~/login'")
+	rootCmd.Flags().StringArrayVarP(&options.Wordlists, "wordlist", "w", nil, "A wordlist as 'path' or 'path:KEYWORD', where path may be '-' for stdin, 'archive.zip:glob', 'builtin:NAME' or a generator such as 'range:0001-9999', 'charset:a-z0-9:1-3', 'date:2020-01-01..2026-12-31:20060102', 'uuid:1000' or 'hex:32:1000' (repeatable, default builtin:common)")
+	rootCmd.Flags().StringVar(&options.Mode, "mode", fuzz.ModeClusterbomb, "How to combine several wordlists (clusterbomb, pitchfork, sniper)")
+	rootCmd.Flags().StringVar(&options.Dedupe, "dedupe", fuzz.DedupeSet, "How to drop repeated wordlist entries (set, bloom, none)")
+	rootCmd.Flags().StringArrayVarP(&options.RuleFiles, "rules", "r", nil, "A hashcat-style rule file applied to every wordlist entry (repeatable, chained)")
+	rootCmd.Flags().StringArrayVar(&options.Rules, "rule", nil, "A rule applied to every wordlist entry, e.g. 'c A{2020..2026}' (repeatable)")
+	rootCmd.Flags().StringArrayVar(&options.Encodings, "encode", nil, "An encoder chain as 'chain' or 'chain:KEYWORD', e.g. 'url,base64', of "+strings.Join(fuzz.EncoderNames(), ", ")+" (repeatable)")
+	rootCmd.Flags().Int64Var(&options.MaxRequests, "max-requests", 0, "Abort if more requests per directory are estimated (0 for no limit)")
 	rootCmd.Flags().IntVarP(&options.Threads, "threads", "t", 10, "The number of threads to use")
-	rootCmd.Flags().IntVarP(&options.Timeout, "timeout", "T", 10, "The request timeout in seconds")
+	rootCmd.Flags().IntVarP(&options.Timeout, "timeout", "T", 10, "The timeout in seconds for each of connecting, the TLS handshake, the response headers and the body (0 for none)")
+	rootCmd.Flags().StringVar(&options.HTTPVersion, "http-version", fuzz.HTTP11, "The HTTP version to use (1.0, 1.1, or 2 to negotiate HTTP/2 over TLS)")
+	rootCmd.Flags().BoolVar(&options.DisableCompression, "no-compression", false, "Do not ask for compressed responses, so sizes are raw sizes")
+	rootCmd.Flags().StringArrayVarP(&options.Proxies, "proxy", "p", nil, "An upstream proxy as http://, https:// or socks5://[user:pass@]host:port (repeatable, used in turn)")
+	rootCmd.Flags().Float64Var(&options.ProxySample, "proxy-sample", 1, "The fraction of requests to send through the proxies")
+	rootCmd.Flags().StringVar(&options.ReplayProxy, "replay-proxy", "", "A proxy that receives a copy of every hit, e.g. http://127.0.0.1:8080 for Burp")
+	rootCmd.Flags().BoolVarP(&options.Insecure, "insecure", "k", false, "Do not verify server certificates")
+	rootCmd.Flags().StringVar(&options.CACertFile, "ca-cert", "", "A PEM bundle of CA certificates to verify servers with")
+	rootCmd.Flags().StringVar(&options.ClientCertFile, "client-cert", "", "A client certificate as a PEM or PKCS#12 file")
+	rootCmd.Flags().StringVar(&options.ClientKeyFile, "client-key", "", "The PEM key of the client certificate, if not in the same file")
+	rootCmd.Flags().StringVar(&options.ClientCertPassword, "client-cert-password", "", "The password of a PKCS#12 client certificate")
+	rootCmd.Flags().StringVar(&options.SNI, "sni", "", "The server name to send in the TLS handshake, regardless of the Host header")
+	rootCmd.Flags().StringVar(&options.TLSMinVersion, "tls-min", "", "The minimum TLS version (1.0, 1.1, 1.2, 1.3)")
+	rootCmd.Flags().StringVar(&options.TLSMaxVersion, "tls-max", "", "The maximum TLS version (1.0, 1.1, 1.2, 1.3)")
+	rootCmd.Flags().StringSliceVar(&options.CipherSuites, "ciphers", nil, "A comma-separated list of cipher suites for TLS 1.2 and below, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
 	rootCmd.Flags().StringVarP(&options.Extensions, "extensions", "x", "", "A comma-separated list of file extensions to scan")
+	rootCmd.Flags().BoolVar(&options.ExtensionsNoDot, "ext-no-dot", false, "Only append extensions to entries without a dot")
 	rootCmd.Flags().StringVarP(&options.IgnoreRegex, "ignore", "i", "", "A regular expression to ignore certain responses")
+	rootCmd.Flags().StringArrayVarP(&options.Matchers, "match", "m", nil, "A rule for responses to keep, e.g. 'status:200-299 && !size:0' or 'duration:>2s' (default '!status:404')")
+	rootCmd.Flags().StringArrayVar(&options.Filters, "filter", nil, "A rule for responses to drop, e.g. 'regex:(?i)not found || header:X-Cache=HIT'")
+	rootCmd.Flags().StringVar(&options.MatchMode, "match-mode", fuzz.ModeOr, "How to combine several match rules (or, and)")
+	rootCmd.Flags().StringVar(&options.FilterMode, "filter-mode", fuzz.ModeOr, "How to combine several filter rules (or, and)")
+	rootCmd.Flags().BoolVar(&options.Anomaly, "anomaly", false, "Report responses much slower than the average, even if filtered")
+	rootCmd.Flags().Float64Var(&options.AnomalyDeviations, "anomaly-deviations", 3, "How many standard deviations above the average a slow response is")
+	rootCmd.Flags().StringSliceVar(&options.IgnorePayloads, "ignore-payload", nil, "A comma-separated list of wordlist entries not to send")
+	rootCmd.Flags().BoolVar(&options.Harvest, "harvest", false, "Collect words from the pages of hits and try them at every directory")
+	rootCmd.Flags().StringVar(&options.HarvestOutput, "harvest-output", "", "Save the harvested words, most frequent first, to a file")
+	rootCmd.Flags().IntVar(&options.HarvestMinLength, "harvest-min-length", 3, "The minimum length of harvested words")
+	rootCmd.Flags().IntVar(&options.HarvestLimit, "harvest-limit", 500, "The maximum number of harvested words to try per directory (0 for no limit)")
+	rootCmd.Flags().BoolVar(&options.Backups, "backups", false, "Probe backup and temporary file variants of every hit, e.g. config.php.bak or admin.zip")
+	rootCmd.Flags().StringVar(&options.BackupTemplateFile, "backup-templates", "", "A file of backup variants as 'TYPE PATTERN' lines with {file}, {base}, {ext} or {dir} (implies --backups)")
 	rootCmd.Flags().StringVarP(&options.OutputFile, "output", "o", "", "The path to the output file")
-	rootCmd.Flags().StringVarP(&options.OutputFormat, "format", "f", "csv", "The output format (csv, json)")
+	rootCmd.Flags().StringVarP(&options.OutputFormat, "format", "f", "csv", "The output format (csv, json, text)")
+	rootCmd.Flags().BoolVar(&options.NoRecursion, "no-recursion", false, "Do not scan below discovered directories")
+	rootCmd.Flags().IntVarP(&options.MaxDepth, "depth", "d", 3, "The maximum recursion depth (0 for no limit)")
+	rootCmd.Flags().BoolVar(&options.NoCalibration, "no-calibration", false, "Do not fingerprint soft-404 responses before scanning")
+	rootCmd.Flags().StringSliceVar(&options.IgnoreDir, "ignore-dir", nil, "A comma-separated list of directories not to recurse into")
+
+	rootCmd.AddCommand(newWordlistsCommand(), newWordlistCommand())
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
@@ -4,7 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+
+	"github.com/your-username/dirfuzz/fuzz"
+	"github.com/your-username/dirfuzz/wordlist"
 )
 
 type options struct {
@@ -13,12 +17,15 @@ type options struct {
 	Timeout      int
 	FilterStatus []int
 	FilterSize   []int
+	FilterWords  string
+	FilterLines  string
 	FilterHeader []string
 	FilterString []string
 	IgnoreDir    []string
 	IgnoreExt    []string
 	IgnoreFile   []string
 	NoRecursion  bool
+	MaxDepth     int
 	UseHTTPS     bool
 	CustomHeader []string
 	CustomData   string
@@ -26,16 +33,19 @@ type options struct {
 
 func (o *options) setDefaults() {
 	o.Threads = 20
-	o.Wordlist = "wordlist.txt"
+	o.Wordlist = wordlist.Prefix + wordlist.Default
 	o.Timeout = 10
 	o.FilterStatus = []int{200, 204, 301, 302, 307, 401, 403}
 	o.FilterSize = []int{500, 1000, 1500}
+	o.FilterWords = ""
+	o.FilterLines = ""
 	o.FilterHeader = []string{"Content-Type: text/html"}
 	o.FilterString = []string{}
 	o.IgnoreDir = []string{"/admin/", "/backup/", "/cgi-bin/"}
 	o.IgnoreExt = []string{".png", ".jpg", ".gif", ".js", ".css"}
 	o.IgnoreFile = []string{}
 	o.NoRecursion = false
+	o.MaxDepth = 3
 	o.UseHTTPS = false
 	o.CustomHeader = []string{}
 	o.CustomData = ""
@@ -50,16 +60,19 @@ func parseOptions() *options {
 	}
 
 	flag.IntVar(&o.Threads, "t", 20, "Number of concurrent threads")
-	flag.StringVar(&o.Wordlist, "w", "wordlist.txt", "Path to the wordlist file")
+	flag.StringVar(&o.Wordlist, "w", wordlist.Prefix+wordlist.Default, "Path to the wordlist file, or builtin:NAME")
 	flag.IntVar(&o.Timeout, "timeout", 10, "Timeout in seconds")
 	filterStatus := flag.String("c", "200,204,301,302,307,401,403", "Filter by status code")
 	filterSize := flag.String("s", "500,1000,1500", "Filter by content length")
+	flag.StringVar(&o.FilterWords, "words", "", "Filter by word count, e.g. 12,40-60")
+	flag.StringVar(&o.FilterLines, "lines", "", "Filter by line count, e.g. 12,40-60")
 	filterHeader := flag.String("H", "Content-Type: text/html", "Filter by header")
-	filterString := flag.String("w", "", "Filter by string")
+	filterString := flag.String("string", "", "Filter by string")
 	ignoreDir := flag.String("i", "/admin/,/backup/,/cgi-bin/", "Ignore directories")
 	ignoreExt := flag.String("e", ".png,.jpg,.gif,.js,.css", "Ignore file extensions")
 	ignoreFile := flag.String("ignore", "", "Ignore specific files")
 	noRecursion := flag.Bool("no-recursion", false, "Disable recursion")
+	flag.IntVar(&o.MaxDepth, "depth", 3, "Maximum recursion depth (0 for no limit)")
 	useHTTPS := flag.Bool("https", false, "Use HTTPS instead of HTTP")
 	customHeader := flag.String("header", "", "Custom header")
 	customData := flag.String("data", "", "Custom data")
@@ -84,3 +97,87 @@ func parseOptions() *options {
 
 	return &o
 }
+
+// fuzzOptions converts the parsed options into scanner options for the
+// given target.
+func (o *options) fuzzOptions(target string) fuzz.Options {
+	scheme := "http://"
+	if o.UseHTTPS {
+		scheme = "https://"
+	}
+	if !strings.Contains(target, "://") {
+		target = scheme + target
+	}
+
+	return fuzz.Options{
+		TargetURL:      target,
+		WordlistFile:   o.Wordlist,
+		Threads:        o.Threads,
+		Timeout:        o.Timeout,
+		NoRecursion:    o.NoRecursion,
+		MaxDepth:       o.MaxDepth,
+		IgnoreDir:      o.IgnoreDir,
+		Matchers:       o.matchers(),
+		MatchMode:      fuzz.ModeAnd,
+		IgnorePayloads: o.IgnoreFile,
+		Headers:        o.CustomHeader,
+		Data:           o.CustomData,
+	}
+}
+
+// matchers converts the filter options into match rules. A response has to
+// satisfy every given option to be kept.
+func (o *options) matchers() []string {
+	var rules []string
+	if len(o.FilterStatus) > 0 {
+		rules = append(rules, "status:"+joinInt(o.FilterStatus))
+	}
+	if len(o.FilterSize) > 0 {
+		rules = append(rules, "size:"+joinInt(o.FilterSize))
+	}
+	if o.FilterWords != "" {
+		rules = append(rules, "words:"+o.FilterWords)
+	}
+	if o.FilterLines != "" {
+		rules = append(rules, "lines:"+o.FilterLines)
+	}
+	for _, header := range o.FilterHeader {
+		rules = append(rules, "header:"+header)
+	}
+	if len(o.FilterString) > 0 {
+		rules = append(rules, "string:"+strings.Join(o.FilterString, ","))
+	}
+	return rules
+}
+
+// splitString splits a comma-separated list, dropping empty entries.
+func splitString(s string) []string {
+	var ret []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// joinInt joins integers into a comma-separated list.
+func joinInt(ns []int) string {
+	s := make([]string, len(ns))
+	for i, n := range ns {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+
+// splitInt splits a comma-separated list of integers, dropping invalid
+// entries.
+func splitInt(s string) []int {
+	var ret []int
+	for _, v := range splitString(s) {
+		if n, err := strconv.Atoi(v); err == nil {
+			ret = append(ret, n)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/your-username/dirfuzz/fuzz"
+)
+
+func TestFuzzOptions(t *testing.T) {
+	var o options
+	o.setDefaults()
+	o.FilterWords = "10-20"
+	o.FilterString = []string{"welcome", "admin"}
+
+	f := o.fuzzOptions("example.org")
+	if f.TargetURL != "http://example.org" || f.WordlistFile != "builtin:common" || f.MaxDepth != 3 || f.MatchMode != fuzz.ModeAnd {
+		t.Errorf("options %+v", f)
+	}
+	if err := f.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	want := "status:200,204,301,302,307,401,403|size:500,1000,1500|words:10-20|header:Content-Type: text/html|string:welcome,admin"
+	if got := strings.Join(f.Matchers, "|"); got != want {
+		t.Errorf("matchers %s, want %s", got, want)
+	}
+
+	// Every rule has to hold for a response to be kept.
+	filter := fuzz.NewFilter()
+	filter.MatchMode = f.MatchMode
+	for _, rule := range f.Matchers {
+		if err := filter.AddMatcher(rule); err != nil {
+			t.Fatalf("%s: %v", rule, err)
+		}
+	}
+	body := []byte("welcome " + strings.Repeat("word ", 10))
+	tests := []struct {
+		status      int
+		contentType string
+		size        int64
+		want        bool
+	}{
+		{200, "text/html; charset=utf-8", 1000, true},
+		{404, "text/html", 1000, false},
+		{200, "application/json", 1000, false},
+		{200, "text/html", 999, false},
+	}
+	for _, tt := range tests {
+		r := &fuzz.Response{
+			StatusCode: tt.status,
+			Size:       tt.size,
+			Words:      fuzz.CountWords(body),
+			Header:     http.Header{"Content-Type": {tt.contentType}},
+			Body:       body,
+		}
+		if got := filter.FilterResponse(r); got != tt.want {
+			t.Errorf("%d %s of %d bytes kept = %v, want %v", tt.status, tt.contentType, tt.size, got, tt.want)
+		}
+	}
+
+	o.UseHTTPS = true
+	if f := o.fuzzOptions("example.org/app"); f.TargetURL != "https://example.org/app" {
+		t.Errorf("target %s", f.TargetURL)
+	}
+	if f := o.fuzzOptions("http://example.org"); f.TargetURL != "http://example.org" {
+		t.Errorf("target %s", f.TargetURL)
+	}
+}
+
+func TestSplitOptions(t *testing.T) {
+	if got := splitString(" a, ,b ,"); strings.Join(got, "|") != "a|b" {
+		t.Errorf("splitString = %q", got)
+	}
+	if got := splitInt("200, x,404"); joinInt(got) != "200,404" {
+		t.Errorf("splitInt = %v", got)
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+
+	"github.com/your-username/dirfuzz/fuzz"
+)
+
+// newWordlistCommand returns the command that merges, cleans and inspects
+// wordlists. Every operation reads its files like -w does, so compressed
+// files, archives, builtin lists and '-' for stdin work everywhere.
+func newWordlistCommand() *cobra.Command {
+	var outputFile string
+
+	cmd := &cobra.Command{
+		Use:   "wordlist",
+		Short: "Merge, clean and inspect wordlists",
+		Long:  `Operations on wordlists that can be chained with pipes. Files are read from stdin when none are given.`,
+		Example: `  dirfuzz wordlist merge a.txt b.txt.gz | dirfuzz wordlist strip | dirfuzz wordlist dedupe -i -o clean.txt
+  dirfuzz wordlist filter --regex '^[a-z]+$' --min-length 3 big.txt
+  dirfuzz wordlist sort --counts logs/*.txt
+  dirfuzz wordlist stats seclists.zip:Discovery/Web-Content/*.txt`,
+	}
+	cmd.PersistentFlags().StringVarP(&outputFile, "output", "o", "", "The file to write to (default: stdout)")
+
+	cmd.AddCommand(
+		newWordlistMergeCommand(&outputFile),
+		newWordlistDedupeCommand(&outputFile),
+		newWordlistFilterCommand(&outputFile),
+		newWordlistStripCommand(&outputFile),
+		newWordlistSortCommand(&outputFile),
+		newWordlistStatsCommand(&outputFile),
+	)
+	return cmd
+}
+
+func newWordlistMergeCommand(outputFile *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "merge [FILE...]",
+		Short: "Concatenate wordlists",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return writeLines(cmd, *outputFile, func(w *bufio.Writer) error {
+				return eachLine(args, func(line string) error {
+					return writeLine(w, line)
+				})
+			})
+		},
+	}
+}
+
+func newWordlistDedupeCommand(outputFile *string) *cobra.Command {
+	var ignoreCase bool
+
+	cmd := &cobra.Command{
+		Use:   "dedupe [FILE...]",
+		Short: "Drop repeated lines, keeping the first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			seen := make(map[string]bool)
+			return writeLines(cmd, *outputFile, func(w *bufio.Writer) error {
+				return eachLine(args, func(line string) error {
+					key := line
+					if ignoreCase {
+						key = strings.ToLower(line)
+					}
+					if seen[key] {
+						return nil
+					}
+					seen[key] = true
+					return writeLine(w, line)
+				})
+			})
+		},
+	}
+	cmd.Flags().BoolVarP(&ignoreCase, "ignore-case", "i", false, "Treat lines differing only in case as repeated")
+	return cmd
+}
+
+func newWordlistFilterCommand(outputFile *string) *cobra.Command {
+	var (
+		match, exclude       string
+		minLength, maxLength int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "filter [FILE...]",
+		Short: "Keep lines matching a regular expression or length",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var matchRe, excludeRe *regexp.Regexp
+			var err error
+			if match != "" {
+				if matchRe, err = regexp.Compile(match); err != nil {
+					return fmt.Errorf("invalid regex %q: %v", match, err)
+				}
+			}
+			if exclude != "" {
+				if excludeRe, err = regexp.Compile(exclude); err != nil {
+					return fmt.Errorf("invalid regex %q: %v", exclude, err)
+				}
+			}
+
+			return writeLines(cmd, *outputFile, func(w *bufio.Writer) error {
+				return eachLine(args, func(line string) error {
+					n := utf8.RuneCountInString(line)
+					if n < minLength || maxLength > 0 && n > maxLength ||
+						matchRe != nil && !matchRe.MatchString(line) ||
+						excludeRe != nil && excludeRe.MatchString(line) {
+						return nil
+					}
+					return writeLine(w, line)
+				})
+			})
+		},
+	}
+	cmd.Flags().StringVar(&match, "regex", "", "Keep only lines matching this regular expression")
+	cmd.Flags().StringVar(&exclude, "exclude", "", "Drop lines matching this regular expression")
+	cmd.Flags().IntVar(&minLength, "min-length", 0, "Drop lines with fewer characters")
+	cmd.Flags().IntVar(&maxLength, "max-length", 0, "Drop lines with more characters (0 for no limit)")
+	return cmd
+}
+
+func newWordlistStripCommand(outputFile *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "strip [FILE...]",
+		Short: "Drop comments and blank lines as the scanner does",
+		Long:  `Trims every line and drops blank lines and comments starting with #, exactly as entries are read during a scan.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return writeLines(cmd, *outputFile, func(w *bufio.Writer) error {
+				return eachLine(args, func(line string) error {
+					if entry, ok := fuzz.ParseEntry(line); ok {
+						return writeLine(w, entry)
+					}
+					return nil
+				})
+			})
+		},
+	}
+}
+
+func newWordlistSortCommand(outputFile *string) *cobra.Command {
+	var counts bool
+
+	cmd := &cobra.Command{
+		Use:   "sort [FILE...]",
+		Short: "Sort entries by frequency, most frequent first",
+		Long:  `Counts how often each entry occurs across all files and prints each once, most frequent first. Comments and blank lines are dropped.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			frequencies, err := countEntries(args)
+			if err != nil {
+				return err
+			}
+			return writeLines(cmd, *outputFile, func(w *bufio.Writer) error {
+				for _, f := range frequencies {
+					if counts {
+						fmt.Fprintf(w, "%d\t", f.count)
+					}
+					if err := writeLine(w, f.entry); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&counts, "counts", false, "Prefix every entry with its count")
+	return cmd
+}
+
+func newWordlistStatsCommand(outputFile *string) *cobra.Command {
+	var top int
+
+	cmd := &cobra.Command{
+		Use:   "stats [FILE...]",
+		Short: "Print line, entry and extension counts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stats, err := collectStats(args)
+			if err != nil {
+				return err
+			}
+			return writeLines(cmd, *outputFile, func(w *bufio.Writer) error {
+				return stats.print(w, top)
+			})
+		},
+	}
+	cmd.Flags().IntVar(&top, "top", 10, "The number of extensions to list (0 for all)")
+	return cmd
+}
+
+// eachLine calls fn with every line of the files, or of stdin without
+// files. Line endings are removed but nothing else is changed.
+func eachLine(files []string, fn func(line string) error) error {
+	if len(files) == 0 {
+		files = []string{fuzz.Stdin}
+	}
+	for _, file := range files {
+		r, err := fuzz.OpenPayloadFile(file)
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if err := fn(strings.TrimRight(scanner.Text(), "\r")); err != nil {
+				r.Close()
+				return err
+			}
+		}
+		err = scanner.Err()
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+// writeLines runs write with a buffered writer to the output file, or to
+// the command's output without one.
+func writeLines(cmd *cobra.Command, outputFile string, write func(w *bufio.Writer) error) error {
+	var out io.Writer = cmd.OutOrStdout()
+	var file *os.File
+	if outputFile != "" {
+		var err error
+		if file, err = os.Create(outputFile); err != nil {
+			return err
+		}
+		out = file
+	}
+
+	w := bufio.NewWriter(out)
+	err := write(w)
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	if file != nil {
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func writeLine(w *bufio.Writer, line string) error {
+	w.WriteString(line)
+	return w.WriteByte('\n')
+}
+
+// frequency is an entry with the number of times it occurs.
+type frequency struct {
+	entry string
+	count int
+}
+
+// countEntries counts the entries of the files, most frequent first and
+// alphabetically among equal counts.
+func countEntries(files []string) ([]frequency, error) {
+	counts := make(map[string]int)
+	err := eachLine(files, func(line string) error {
+		if entry, ok := fuzz.ParseEntry(line); ok {
+			counts[entry]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	frequencies := make([]frequency, 0, len(counts))
+	for entry, count := range counts {
+		frequencies = append(frequencies, frequency{entry, count})
+	}
+	sort.Slice(frequencies, func(i, j int) bool {
+		if frequencies[i].count != frequencies[j].count {
+			return frequencies[i].count > frequencies[j].count
+		}
+		return frequencies[i].entry < frequencies[j].entry
+	})
+	return frequencies, nil
+}
+
+// wordlistStats describes the lines of wordlists.
+type wordlistStats struct {
+	lines    int
+	blank    int
+	comments int
+	entries  int
+	// unique and uniqueFold count distinct entries, exactly and ignoring
+	// case.
+	unique     map[string]bool
+	uniqueFold map[string]bool
+	minLength  int
+	maxLength  int
+	totalChars int
+	// directories end with a slash, extensions counts the extensions of
+	// the other entries.
+	directories int
+	extensions  map[string]int
+}
+
+// collectStats reads the files and counts their lines.
+func collectStats(files []string) (*wordlistStats, error) {
+	s := &wordlistStats{
+		unique:     make(map[string]bool),
+		uniqueFold: make(map[string]bool),
+		extensions: make(map[string]int),
+	}
+	err := eachLine(files, func(line string) error {
+		s.lines++
+		entry, ok := fuzz.ParseEntry(line)
+		if !ok {
+			if strings.TrimSpace(line) == "" {
+				s.blank++
+			} else {
+				s.comments++
+			}
+			return nil
+		}
+
+		s.entries++
+		s.unique[entry] = true
+		s.uniqueFold[strings.ToLower(entry)] = true
+		n := utf8.RuneCountInString(entry)
+		if s.entries == 1 || n < s.minLength {
+			s.minLength = n
+		}
+		if n > s.maxLength {
+			s.maxLength = n
+		}
+		s.totalChars += n
+
+		if strings.HasSuffix(entry, "/") {
+			s.directories++
+		} else if ext := path.Ext(entry); ext != "" && ext != entry {
+			s.extensions[strings.ToLower(ext)]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// print writes the statistics with the top most common extensions, or all
+// of them if top is 0.
+func (s *wordlistStats) print(w io.Writer, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Lines\t%d\n", s.lines)
+	fmt.Fprintf(tw, "Blank lines\t%d\n", s.blank)
+	fmt.Fprintf(tw, "Comments\t%d\n", s.comments)
+	fmt.Fprintf(tw, "Entries\t%d\n", s.entries)
+	fmt.Fprintf(tw, "Unique entries\t%d\n", len(s.unique))
+	fmt.Fprintf(tw, "Unique ignoring case\t%d\n", len(s.uniqueFold))
+	fmt.Fprintf(tw, "Duplicates\t%d\n", s.entries-len(s.unique))
+	if s.entries > 0 {
+		fmt.Fprintf(tw, "Length\tmin %d, max %d, average %.1f\n", s.minLength, s.maxLength, float64(s.totalChars)/float64(s.entries))
+	}
+	fmt.Fprintf(tw, "Directories\t%d\n", s.directories)
+
+	extensions := make([]frequency, 0, len(s.extensions))
+	withExtension := 0
+	for ext, count := range s.extensions {
+		extensions = append(extensions, frequency{ext, count})
+		withExtension += count
+	}
+	sort.Slice(extensions, func(i, j int) bool {
+		if extensions[i].count != extensions[j].count {
+			return extensions[i].count > extensions[j].count
+		}
+		return extensions[i].entry < extensions[j].entry
+	})
+	fmt.Fprintf(tw, "With extension\t%d\n", withExtension)
+	if top > 0 && len(extensions) > top {
+		extensions = extensions[:top]
+	}
+	for _, e := range extensions {
+		fmt.Fprintf(tw, "  %s\t%d (%.1f%%)\n", e.entry, e.count, 100*float64(e.count)/float64(s.entries))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes a file to a temporary directory and returns its path.
+func writeFile(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// runWordlist runs "dirfuzz wordlist" with the arguments and returns what
+// it printed.
+func runWordlist(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := newWordlistCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestWordlistCommands(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("Admin\n# old\nlogin\n"))
+	zw.Close()
+	plain := writeFile(t, "a.txt", []byte("admin\r\n  login \r\n\r\nadmin\r\nbackup.tar.gz\r\n"))
+	compressed := writeFile(t, "b.txt.gz", gz.Bytes())
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"merge", plain, compressed}, "admin\n  login \n\nadmin\nbackup.tar.gz\nAdmin\n# old\nlogin\n"},
+		{[]string{"dedupe", plain, compressed}, "admin\n  login \n\nbackup.tar.gz\nAdmin\n# old\nlogin\n"},
+		{[]string{"dedupe", "-i", plain, compressed}, "admin\n  login \n\nbackup.tar.gz\n# old\nlogin\n"},
+		{[]string{"strip", plain, compressed}, "admin\nlogin\nadmin\nbackup.tar.gz\nAdmin\nlogin\n"},
+		{[]string{"filter", "--regex", "^[a-z]+$", plain, compressed}, "admin\nadmin\nlogin\n"},
+		{[]string{"filter", "--exclude", "^#", "--min-length", "1", "--max-length", "5", plain, compressed}, "admin\nadmin\nAdmin\nlogin\n"},
+		{[]string{"sort", plain, compressed}, "admin\nlogin\nAdmin\nbackup.tar.gz\n"},
+		{[]string{"sort", "--counts", plain, compressed}, "2\tadmin\n2\tlogin\n1\tAdmin\n1\tbackup.tar.gz\n"},
+	}
+	for _, tt := range tests {
+		got, err := runWordlist(t, tt.args...)
+		if err != nil {
+			t.Errorf("%s: %v", tt.args[0], err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q printed %q, want %q", tt.args[:len(tt.args)-2], got, tt.want)
+		}
+	}
+}
+
+func TestWordlistOutputFile(t *testing.T) {
+	in := writeFile(t, "in.txt", []byte("b\na\nb\n"))
+	out := filepath.Join(t.TempDir(), "out.txt")
+	printed, err := runWordlist(t, "dedupe", "-o", out, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	if printed != "" || string(data) != "b\na\n" {
+		t.Errorf("printed %q, wrote %q", printed, data)
+	}
+}
+
+func TestWordlistErrors(t *testing.T) {
+	in := writeFile(t, "in.txt", []byte("a\n"))
+	for _, args := range [][]string{
+		{"merge", filepath.Join(t.TempDir(), "missing.txt")},
+		{"filter", "--regex", "(", in},
+		{"filter", "--exclude", "[", in},
+		{"stats", "builtin:missing"},
+	} {
+		if _, err := runWordlist(t, args...); err == nil {
+			t.Errorf("%q succeeded", args)
+		}
+	}
+}
+
+func TestCollectStats(t *testing.T) {
+	in := writeFile(t, "in.txt", []byte("# comment\n\nadmin/\nindex.php\nIndex.PHP\nindex.php\n.htaccess\nbackup.tar.gz\nlogin\n"))
+	s, err := collectStats([]string{in})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.lines != 9 || s.blank != 1 || s.comments != 1 || s.entries != 7 {
+		t.Errorf("lines %d, blank %d, comments %d, entries %d", s.lines, s.blank, s.comments, s.entries)
+	}
+	if len(s.unique) != 6 || len(s.uniqueFold) != 5 || s.directories != 1 {
+		t.Errorf("unique %d, ignoring case %d, directories %d", len(s.unique), len(s.uniqueFold), s.directories)
+	}
+	if s.minLength != 5 || s.maxLength != 13 {
+		t.Errorf("lengths %d to %d", s.minLength, s.maxLength)
+	}
+	// .htaccess is a name, not an extension.
+	if len(s.extensions) != 2 || s.extensions[".php"] != 3 || s.extensions[".gz"] != 1 {
+		t.Errorf("extensions %v", s.extensions)
+	}
+
+	var out bytes.Buffer
+	if err := s.print(&out, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Entries               7\n", "Duplicates            1\n", "With extension        4\n", "  .php                3 (42.9%)\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("stats lack %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), ".gz") {
+		t.Errorf("stats list more than the top extension:\n%s", out.String())
+	}
+}
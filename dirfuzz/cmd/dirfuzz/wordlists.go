@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/your-username/dirfuzz/wordlist"
+)
+
+// newWordlistsCommand returns the command that lists and exports the
+// builtin wordlists.
+func newWordlistsCommand() *cobra.Command {
+	var export, outputFile string
+
+	cmd := &cobra.Command{
+		Use:   "wordlists",
+		Short: "List or export the builtin wordlists",
+		Long:  `Lists the wordlists compiled into dirfuzz, which can be used with -w builtin:NAME, or exports one of them.`,
+		Example: `  dirfuzz wordlists
+  dirfuzz wordlists --export common -o common.txt`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if export == "" {
+				return printWordlists(cmd.OutOrStdout())
+			}
+
+			if outputFile == "" {
+				return wordlist.Export(export, cmd.OutOrStdout())
+			}
+			file, err := os.Create(outputFile)
+			if err != nil {
+				return err
+			}
+			if err := wordlist.Export(export, file); err != nil {
+				file.Close()
+				return err
+			}
+			return file.Close()
+		},
+	}
+
+	cmd.Flags().StringVarP(&export, "export", "e", "", "The name of the wordlist to export")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "The file to export to (default: stdout)")
+	return cmd
+}
+
+// printWordlists prints the builtin wordlists as a table.
+func printWordlists(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tENTRIES\tSIZE\tDESCRIPTION")
+	for _, l := range wordlist.Lists() {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", l.Name, l.Entries, l.Size, l.Description)
+	}
+	return tw.Flush()
+}
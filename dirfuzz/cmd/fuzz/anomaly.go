@@ -0,0 +1,45 @@
+package fuzz
+
+import (
+	"math"
+	"time"
+)
+
+// anomalyMinSamples is the number of responses needed before the latency
+// baseline is trusted.
+const anomalyMinSamples = 30
+
+// latencyBaseline keeps the running mean and variance of response times,
+// using Welford's algorithm so no samples need to be kept.
+type latencyBaseline struct {
+	n    int
+	mean float64
+	m2   float64
+}
+
+// add adds a response time to the baseline.
+func (b *latencyBaseline) add(d time.Duration) {
+	x := float64(d)
+	b.n++
+	delta := x - b.mean
+	b.mean += delta / float64(b.n)
+	b.m2 += delta * (x - b.mean)
+}
+
+// stddev returns the standard deviation of the response times.
+func (b *latencyBaseline) stddev() float64 {
+	if b.n < 2 {
+		return 0
+	}
+	return math.Sqrt(b.m2 / float64(b.n-1))
+}
+
+// isAnomaly reports whether a response time is more than k standard
+// deviations above the mean. Anomalies should not be added to the baseline,
+// so a run of slow responses keeps standing out.
+func (b *latencyBaseline) isAnomaly(d time.Duration, k float64) bool {
+	if b.n < anomalyMinSamples {
+		return false
+	}
+	return float64(d) > b.mean+k*b.stddev()
+}
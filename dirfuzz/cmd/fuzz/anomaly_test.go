@@ -0,0 +1,55 @@
+package fuzz
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyBaseline(t *testing.T) {
+	var b latencyBaseline
+	for i := 0; i < anomalyMinSamples-1; i++ {
+		b.add(time.Duration(100+i%3) * time.Millisecond)
+	}
+	if b.isAnomaly(time.Hour, 3) {
+		t.Error("anomaly reported before the baseline has enough samples")
+	}
+	b.add(101 * time.Millisecond)
+
+	if mean := time.Duration(b.mean); mean < 100*time.Millisecond || mean > 102*time.Millisecond {
+		t.Errorf("mean %v", mean)
+	}
+	if sd := time.Duration(b.stddev()); sd < 500*time.Microsecond || sd > 1500*time.Microsecond {
+		t.Errorf("standard deviation %v", sd)
+	}
+	if b.isAnomaly(102*time.Millisecond, 3) {
+		t.Error("a usual response time is an anomaly")
+	}
+	if !b.isAnomaly(150*time.Millisecond, 3) {
+		t.Error("a slow response is not an anomaly")
+	}
+	if b.isAnomaly(150*time.Millisecond, 100) {
+		t.Error("a slow response within 100 deviations is an anomaly")
+	}
+}
+
+func TestScanReportsSlowResponses(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(300 * time.Millisecond)
+		}
+		http.NotFound(w, r)
+	}
+	var words strings.Builder
+	for i := 0; i < anomalyMinSamples+5; i++ {
+		fmt.Fprintf(&words, "fast%d\n", i)
+	}
+	words.WriteString("slow\n")
+	wordlist := writeFile(t, "words.txt", words.String())
+	got := scan(t, handler, Options{WordlistFile: wordlist, Threads: 1, NoRecursion: true, NoCalibration: true, Anomaly: true, AnomalyDeviations: 3})
+	if strings.Join(got, " ") != "/slow" {
+		t.Errorf("found %v, want the slow 404", got)
+	}
+}
@@ -0,0 +1,402 @@
+package fuzz
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Authentication schemes of Options.Auth.
+const (
+	AuthBasic  = "basic"
+	AuthDigest = "digest"
+	AuthBearer = "bearer"
+	AuthNTLM   = "ntlm"
+)
+
+// credentials are the parsed Options.Auth.
+type credentials struct {
+	scheme   string
+	domain   string // NTLM only
+	user     string
+	password string
+	token    string // Bearer only
+}
+
+// parseAuth parses the authentication option: "basic:USER:PASS",
+// "digest:USER:PASS", "bearer:TOKEN" or "ntlm:[DOMAIN\]USER:PASS". NTLM also
+// takes "USER@DOMAIN". It returns nil for the empty string.
+func parseAuth(spec string) (*credentials, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	scheme, value, _ := strings.Cut(spec, ":")
+	c := &credentials{scheme: strings.ToLower(scheme)}
+	switch c.scheme {
+	case AuthBearer:
+		if value == "" {
+			return nil, errors.New("empty bearer token")
+		}
+		c.token = value
+		return c, nil
+	case AuthBasic, AuthDigest, AuthNTLM:
+	default:
+		return nil, fmt.Errorf("invalid authentication %q (want basic:USER:PASS, digest:USER:PASS, bearer:TOKEN or ntlm:[DOMAIN\\]USER:PASS)", spec)
+	}
+
+	user, password, ok := strings.Cut(value, ":")
+	if !ok || user == "" {
+		return nil, fmt.Errorf("invalid %s credentials (want USER:PASS)", c.scheme)
+	}
+	c.user, c.password = user, password
+	if c.scheme == AuthNTLM {
+		if domain, user, ok := strings.Cut(c.user, `\`); ok {
+			c.domain, c.user = domain, user
+		} else if user, domain, ok := strings.Cut(c.user, "@"); ok {
+			c.domain, c.user = domain, user
+		}
+	}
+	return c, nil
+}
+
+// validateAuth checks the authentication option against the HTTP version.
+// NTLM authenticates a connection, which HTTP/1.0 closes after every request
+// and HTTP/2 shares between requests.
+func validateAuth(o Options) error {
+	c, err := parseAuth(o.Auth)
+	if err != nil {
+		return err
+	}
+	if c != nil && c.scheme == AuthNTLM && (o.HTTPVersion == HTTP10 || o.HTTPVersion == HTTP2) {
+		return errors.New("NTLM authentication needs HTTP/1.1")
+	}
+	return nil
+}
+
+// newClientTransport returns the transport of newTransport with the
+// authentication of the options, for Threads concurrent requests.
+func newClientTransport(options Options, config *tls.Config) http.RoundTripper {
+	return withAuth(options, options.Threads, func() *http.Transport {
+		return newTransport(options, config)
+	})
+}
+
+// withAuth adds the authentication of the options to the transports made by
+// newBase. NTLM needs a transport per connection, size of them; the other
+// schemes share one.
+func withAuth(options Options, size int, newBase func() *http.Transport) http.RoundTripper {
+	// The authentication was checked by Options.Validate.
+	c, err := parseAuth(options.Auth)
+	if err != nil || c == nil {
+		return newBase()
+	}
+	if c.scheme == AuthNTLM {
+		return newNTLMTransport(c, size, newBase)
+	}
+	return &authTransport{base: newBase(), credentials: c}
+}
+
+// authTransport sends Basic and Bearer credentials with every request, and
+// answers Digest challenges. Once a Digest nonce is known, it is used for
+// the following requests until the server sends a new one. Requests that
+// already carry an Authorization header, such as a login token, are sent
+// unchanged.
+type authTransport struct {
+	base        http.RoundTripper
+	credentials *credentials
+
+	mu     sync.Mutex
+	digest *digestChallenge
+	nc     uint32 // the requests sent with the nonce of digest
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+	r := req.Clone(req.Context())
+	switch t.credentials.scheme {
+	case AuthBasic:
+		r.SetBasicAuth(t.credentials.user, t.credentials.password)
+		return t.base.RoundTrip(r)
+	case AuthBearer:
+		r.Header.Set("Authorization", "Bearer "+t.credentials.token)
+		return t.base.RoundTrip(r)
+	}
+
+	nonce := t.authorizeDigest(r)
+	resp, err := t.base.RoundTrip(r)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	c := parseDigestChallenge(resp.Header)
+	// The same nonce again without stale=true means the credentials were
+	// rejected.
+	if c == nil || c.nonce == nonce && !c.stale {
+		return resp, nil
+	}
+	retry := rewind(req)
+	if retry == nil {
+		return resp, nil
+	}
+	drain(resp)
+
+	t.mu.Lock()
+	t.digest, t.nc = c, 0
+	t.mu.Unlock()
+	t.authorizeDigest(retry)
+	return t.base.RoundTrip(retry)
+}
+
+// authorizeDigest adds the Digest authorization for the known nonce, if
+// any, and returns the nonce.
+func (t *authTransport) authorizeDigest(req *http.Request) string {
+	t.mu.Lock()
+	c := t.digest
+	t.nc++
+	nc := t.nc
+	t.mu.Unlock()
+	if c == nil {
+		return ""
+	}
+	if header, err := c.authorization(t.credentials, req, nc); err == nil {
+		req.Header.Set("Authorization", header)
+	}
+	return c.nonce
+}
+
+// digestChallenge is a Digest challenge of a WWW-Authenticate header.
+type digestChallenge struct {
+	realm, nonce, opaque, algorithm, qop string
+	stale                                bool
+}
+
+// digestHashes are the supported Digest algorithms, without the -sess
+// suffix.
+var digestHashes = map[string]func() hash.Hash{
+	"MD5":         md5.New,
+	"SHA-256":     sha256.New,
+	"SHA-512-256": sha512.New512_256,
+}
+
+// parseDigestChallenge returns the first Digest challenge of the response
+// headers with a supported algorithm, or nil.
+func parseDigestChallenge(header http.Header) *digestChallenge {
+	for _, challenge := range parseChallenges(header.Values("Www-Authenticate")) {
+		if challenge.scheme != AuthDigest || challenge.params["nonce"] == "" {
+			continue
+		}
+		c := &digestChallenge{
+			realm:     challenge.params["realm"],
+			nonce:     challenge.params["nonce"],
+			opaque:    challenge.params["opaque"],
+			algorithm: challenge.params["algorithm"],
+			stale:     strings.EqualFold(challenge.params["stale"], "true"),
+		}
+		if c.algorithm == "" {
+			c.algorithm = "MD5"
+		}
+		if digestHashes[strings.TrimSuffix(strings.ToUpper(c.algorithm), "-SESS")] == nil {
+			continue
+		}
+		// auth is preferred to auth-int, which hashes the body.
+		for _, qop := range strings.Split(challenge.params["qop"], ",") {
+			qop = strings.TrimSpace(qop)
+			if qop == "auth" || qop == "auth-int" && c.qop == "" {
+				c.qop = qop
+			}
+		}
+		return c
+	}
+	return nil
+}
+
+// authorization returns the Authorization header of a request as described
+// in RFC 7616, with a random client nonce.
+func (c *digestChallenge) authorization(cred *credentials, req *http.Request, nc uint32) (string, error) {
+	cnonce := make([]byte, 16)
+	if _, err := rand.Read(cnonce); err != nil {
+		return "", err
+	}
+	return c.authorize(cred, req, nc, hex.EncodeToString(cnonce))
+}
+
+// authorize returns the Authorization header of a request with the client
+// nonce cn.
+func (c *digestChallenge) authorize(cred *credentials, req *http.Request, nc uint32, cn string) (string, error) {
+	algorithm := strings.ToUpper(c.algorithm)
+	newHash := digestHashes[strings.TrimSuffix(algorithm, "-SESS")]
+	h := func(s string) string {
+		sum := newHash()
+		io.WriteString(sum, s)
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	count := fmt.Sprintf("%08x", nc)
+	uri := req.URL.RequestURI()
+
+	ha1 := h(cred.user + ":" + c.realm + ":" + cred.password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cn)
+	}
+	ha2 := h(req.Method + ":" + uri)
+	if c.qop == "auth-int" {
+		body := []byte{}
+		if req.GetBody != nil {
+			rc, err := req.GetBody()
+			if err != nil {
+				return "", err
+			}
+			body, err = io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+		sum := newHash()
+		sum.Write(body)
+		ha2 = h(req.Method + ":" + uri + ":" + hex.EncodeToString(sum.Sum(nil)))
+	}
+
+	var response string
+	if c.qop == "" {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + count + ":" + cn + ":" + c.qop + ":" + ha2)
+	}
+
+	header := fmt.Sprintf(`Digest username=%q, realm=%q, nonce=%q, uri=%q, algorithm=%s, response=%q`,
+		cred.user, c.realm, c.nonce, uri, c.algorithm, response)
+	if c.opaque != "" {
+		header += fmt.Sprintf(", opaque=%q", c.opaque)
+	}
+	if c.qop != "" {
+		header += fmt.Sprintf(", qop=%s, nc=%s, cnonce=%q", c.qop, count, cn)
+	}
+	return header, nil
+}
+
+// authChallenge is a challenge of a WWW-Authenticate header, with either
+// auth-params or a token68 such as an NTLM message.
+type authChallenge struct {
+	scheme string // lower case
+	token  string
+	params map[string]string // by lower case name
+}
+
+// parseChallenges parses WWW-Authenticate header values, each of which may
+// hold several comma separated challenges.
+func parseChallenges(values []string) []authChallenge {
+	var challenges []authChallenge
+	for _, s := range values {
+		for s = strings.TrimLeft(s, " \t,"); s != ""; s = strings.TrimLeft(s, " \t,") {
+			var name string
+			name, s = cutToken(s)
+			if name == "" {
+				break
+			}
+			rest := strings.TrimLeft(s, " \t")
+			if strings.HasPrefix(rest, "=") && len(challenges) > 0 {
+				// An auth-param of the last challenge.
+				var value string
+				value, s = cutValue(strings.TrimLeft(rest[1:], " \t"))
+				challenges[len(challenges)-1].params[strings.ToLower(name)] = value
+				continue
+			}
+			c := authChallenge{scheme: strings.ToLower(name), params: make(map[string]string)}
+			c.token = token68(rest)
+			s = rest[len(c.token):]
+			challenges = append(challenges, c)
+		}
+	}
+	return challenges
+}
+
+// cutToken splits a leading HTTP token off s.
+func cutToken(s string) (string, string) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !(r < 0x80 && (isAlphanumeric(byte(r)) || strings.ContainsRune("!#$%&'*+-.^_`|~", r)))
+	})
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+// cutValue splits a leading token or quoted string off s.
+func cutValue(s string) (string, string) {
+	if !strings.HasPrefix(s, `"`) {
+		return cutToken(s)
+	}
+	var value strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				value.WriteByte(s[i])
+			}
+		case '"':
+			return value.String(), s[i+1:]
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+	return value.String(), ""
+}
+
+// token68 returns the token68 at the start of s, if it is all there is
+// before the next comma.
+func token68(s string) string {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !(r < 0x80 && (isAlphanumeric(byte(r)) || strings.ContainsRune("-._~+/", r)))
+	})
+	if i < 0 {
+		return s
+	}
+	if i == 0 {
+		return ""
+	}
+	for i < len(s) && s[i] == '=' {
+		i++
+	}
+	if tail := strings.TrimLeft(s[i:], " \t"); tail != "" && tail[0] != ',' {
+		return ""
+	}
+	return s[:i]
+}
+
+// rewind returns a copy of the request that can be sent again, or nil if
+// its body cannot be read again.
+func rewind(req *http.Request) *http.Request {
+	r := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return r
+	}
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	r.Body = body
+	return r
+}
+
+// drain reads and closes the body of a response that is not returned, so
+// that its connection can be reused.
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+}
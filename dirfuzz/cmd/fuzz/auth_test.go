@@ -0,0 +1,366 @@
+package fuzz
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseAuth(t *testing.T) {
+	tests := []struct {
+		spec string
+		want credentials
+	}{
+		{"basic:bob:p:w", credentials{scheme: AuthBasic, user: "bob", password: "p:w"}},
+		{"Digest:bob:", credentials{scheme: AuthDigest, user: "bob"}},
+		{"bearer:a:b", credentials{scheme: AuthBearer, token: "a:b"}},
+		{`ntlm:CORP\bob:pw`, credentials{scheme: AuthNTLM, domain: "CORP", user: "bob", password: "pw"}},
+		{"ntlm:bob@corp.example:pw", credentials{scheme: AuthNTLM, domain: "corp.example", user: "bob", password: "pw"}},
+		{"ntlm:bob:pw", credentials{scheme: AuthNTLM, user: "bob", password: "pw"}},
+	}
+	for _, tt := range tests {
+		c, err := parseAuth(tt.spec)
+		if err != nil {
+			t.Errorf("parseAuth(%q): %v", tt.spec, err)
+			continue
+		}
+		if *c != tt.want {
+			t.Errorf("parseAuth(%q) = %+v, want %+v", tt.spec, *c, tt.want)
+		}
+	}
+	if c, err := parseAuth(""); c != nil || err != nil {
+		t.Errorf("parseAuth(\"\") = %v, %v", c, err)
+	}
+	for _, spec := range []string{"foo:x", "basic:nopass", "basic::pw", "bearer:", "ntlm"} {
+		if _, err := parseAuth(spec); err == nil {
+			t.Errorf("parseAuth(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestValidateAuth(t *testing.T) {
+	for _, version := range []string{HTTP10, HTTP2} {
+		if err := validateAuth(Options{Auth: "ntlm:bob:pw", HTTPVersion: version}); err == nil {
+			t.Errorf("NTLM accepted over HTTP version %s", version)
+		}
+	}
+	if err := validateAuth(Options{Auth: "digest:bob:pw", HTTPVersion: HTTP2}); err != nil {
+		t.Errorf("Digest over HTTP/2: %v", err)
+	}
+}
+
+func TestParseChallenges(t *testing.T) {
+	got := parseChallenges([]string{
+		`Negotiate, NTLM`,
+		`Digest realm="a \"b\", c", nonce=xyz, QOP="auth,auth-int", Basic realm=z`,
+		`NTLM TlRMTVNTUAACAAAA==`,
+		`Bearer error="invalid_token"`,
+	})
+	want := []string{
+		"negotiate [] ''",
+		"ntlm [] ''",
+		`digest [nonce=xyz qop=auth,auth-int realm=a "b", c] ''`,
+		"basic [realm=z] ''",
+		"ntlm [] 'TlRMTVNTUAACAAAA=='",
+		"bearer [error=invalid_token] ''",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parsed %d challenges %v, want %d", len(got), got, len(want))
+	}
+	for i, c := range got {
+		var params []string
+		for _, name := range []string{"error", "nonce", "qop", "realm"} {
+			if value, ok := c.params[name]; ok {
+				params = append(params, name+"="+value)
+			}
+		}
+		if s := fmt.Sprintf("%s %v '%s'", c.scheme, params, c.token); s != want[i] {
+			t.Errorf("challenge %d = %s, want %s", i, s, want[i])
+		}
+	}
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	tests := []struct {
+		header []string
+		want   *digestChallenge
+	}{
+		{[]string{`Basic realm=x`}, nil},
+		{[]string{`Digest realm=x`}, nil},
+		{
+			[]string{`Digest realm="r", nonce="n", qop="auth-int, auth", stale=TRUE`},
+			&digestChallenge{realm: "r", nonce: "n", algorithm: "MD5", qop: "auth", stale: true},
+		},
+		{
+			[]string{`Digest realm="r", nonce="n", algorithm=SHA-512, qop=auth-int`, `Digest nonce="m", opaque=o, algorithm=sha-256-sess`},
+			&digestChallenge{nonce: "m", opaque: "o", algorithm: "sha-256-sess"},
+		},
+	}
+	for _, tt := range tests {
+		header := http.Header{"Www-Authenticate": tt.header}
+		got := parseDigestChallenge(header)
+		if got == nil || tt.want == nil {
+			if got != tt.want {
+				t.Errorf("parseDigestChallenge(%q) = %+v, want %+v", tt.header, got, tt.want)
+			}
+			continue
+		}
+		if *got != *tt.want {
+			t.Errorf("parseDigestChallenge(%q) = %+v, want %+v", tt.header, *got, *tt.want)
+		}
+	}
+}
+
+// The examples of RFC 7616, section 3.9.1.
+func TestDigestAuthorizationRFC7616(t *testing.T) {
+	cred := &credentials{scheme: AuthDigest, user: "Mufasa", password: "Circle of Life"}
+	req, _ := http.NewRequest(http.MethodGet, "http://www.example.org/dir/index.html", nil)
+	tests := []struct {
+		algorithm, response string
+	}{
+		{"MD5", "8ca523f5e9506fed4657c9700eebdbec"},
+		{"SHA-256", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	}
+	for _, tt := range tests {
+		c := &digestChallenge{
+			realm:     "http-auth@example.org",
+			nonce:     "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+			opaque:    "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+			algorithm: tt.algorithm,
+			qop:       "auth",
+		}
+		header, err := c.authorize(cred, req, 1, "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := parseChallenges([]string{header})
+		if len(got) != 1 || got[0].scheme != AuthDigest {
+			t.Fatalf("%s: unparsable header %s", tt.algorithm, header)
+		}
+		params := got[0].params
+		if params["response"] != tt.response {
+			t.Errorf("%s: response %s, want %s", tt.algorithm, params["response"], tt.response)
+		}
+		if params["uri"] != "/dir/index.html" || params["nc"] != "00000001" || params["opaque"] != c.opaque || params["username"] != "Mufasa" {
+			t.Errorf("%s: header %s", tt.algorithm, header)
+		}
+	}
+}
+
+// ntlmChallenge returns a CHALLENGE_MESSAGE with the server challenge and
+// target info.
+func ntlmChallenge(serverChallenge, targetInfo []byte) []byte {
+	msg := make([]byte, 48)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 2)
+	binary.LittleEndian.PutUint32(msg[20:], ntlmNegotiateFlags)
+	copy(msg[24:], serverChallenge)
+	binary.LittleEndian.PutUint16(msg[40:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint16(msg[42:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(msg[44:], 48)
+	return append(msg, targetInfo...)
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// The NTLMv2 example of MS-NLMP, section 4.2.4.
+func TestNTLMv2AuthenticateMSNLMP(t *testing.T) {
+	key := ntowfv2("User", "Password", "Domain")
+	if got := hex.EncodeToString(key); got != "0c868a403bfd7a93a3001ef22ef02e3f" {
+		t.Errorf("NTOWFv2 = %s", got)
+	}
+
+	cred := &credentials{scheme: AuthNTLM, domain: "Domain", user: "User", password: "Password"}
+	targetInfo := mustHex(t, "02000c0044006f006d00610069006e0001000c005300650072007600650072000000000000")[:36]
+	challenge := ntlmChallenge(mustHex(t, "0123456789abcdef"), targetInfo)
+	msg, err := ntlmAuthenticateAt(cred, challenge, mustHex(t, "aaaaaaaaaaaaaaaa"), make([]byte, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg[:8], ntlmSignature) || binary.LittleEndian.Uint32(msg[8:]) != 3 {
+		t.Fatalf("not an AUTHENTICATE_MESSAGE: %x", msg)
+	}
+	lm, _ := ntlmField(msg, 12)
+	nt, _ := ntlmField(msg, 20)
+	domain, _ := ntlmField(msg, 28)
+	user, _ := ntlmField(msg, 36)
+	if got := hex.EncodeToString(lm); got != "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa" {
+		t.Errorf("LMv2 response = %s", got)
+	}
+	if got := hex.EncodeToString(nt[:16]); got != "68cd0ab851e51c96aabc927bebef6a1c" {
+		t.Errorf("NTProofStr = %s", got)
+	}
+	if !bytes.Equal(domain, utf16le("Domain")) || !bytes.Equal(user, utf16le("User")) {
+		t.Errorf("domain %x and user %x", domain, user)
+	}
+}
+
+func TestNTLMAuthenticateServerTimestamp(t *testing.T) {
+	cred := &credentials{scheme: AuthNTLM, user: "bob", password: "pw"}
+	timestamp := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	targetInfo := append([]byte{7, 0, 8, 0}, timestamp...)
+	targetInfo = append(targetInfo, 0, 0, 0, 0)
+	msg, err := ntlmAuthenticateAt(cred, ntlmChallenge(make([]byte, 8), targetInfo), make([]byte, 8), make([]byte, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lm, _ := ntlmField(msg, 12)
+	nt, _ := ntlmField(msg, 20)
+	if !bytes.Equal(lm, make([]byte, 24)) {
+		t.Errorf("LMv2 response %x sent with a server timestamp", lm)
+	}
+	if !bytes.Equal(nt[24:32], timestamp) {
+		t.Errorf("blob timestamp %x, want the server's", nt[24:32])
+	}
+
+	for _, challenge := range [][]byte{nil, ntlmNegotiate(), ntlmChallenge(make([]byte, 8), nil)[:40]} {
+		if _, err := ntlmAuthenticateAt(cred, challenge, make([]byte, 8), make([]byte, 8)); err == nil {
+			t.Errorf("answered the invalid challenge %x", challenge)
+		}
+	}
+}
+
+func TestScanDigestAuth(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		// The nonce changes every four requests.
+		nonce := fmt.Sprint("n", requests/4)
+		requests++
+		mu.Unlock()
+
+		challenges := parseChallenges(r.Header.Values("Authorization"))
+		if len(challenges) == 1 && challenges[0].scheme == AuthDigest {
+			p := challenges[0].params
+			h := func(s string) string { sum := md5.Sum([]byte(s)); return hex.EncodeToString(sum[:]) }
+			ha1 := h("bob:realm:secret")
+			ha2 := h(r.Method + ":" + r.URL.RequestURI())
+			if p["nonce"] == nonce && p["response"] == h(ha1+":"+nonce+":"+p["nc"]+":"+p["cnonce"]+":auth:"+ha2) {
+				softNotFound(w, r)
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="realm", nonce="%s", qop="auth", stale=%v`, nonce, len(challenges) > 0))
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	wordlist := writeFile(t, "words.txt", "a\nb\nadmin\nc\nd\ne\nf\n")
+	got := scan(t, handler, Options{WordlistFile: wordlist, Threads: 1, NoRecursion: true, NoCalibration: true, Auth: "digest:bob:secret", Matchers: []string{"regex:admin panel"}})
+	if strings.Join(got, " ") != "/admin" {
+		t.Errorf("found %v with Digest authentication", got)
+	}
+}
+
+// roundTripFunc is an http.RoundTripper calling the function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestAuthKeepsAuthorization(t *testing.T) {
+	var sent string
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = req.Header.Get("Authorization")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	for _, auth := range []string{"basic:bob:secret", "bearer:tok", "digest:bob:secret"} {
+		c, err := parseAuth(auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transport := &authTransport{base: base, credentials: c}
+		req, _ := http.NewRequest("GET", "http://h/", nil)
+		req.Header.Set("Authorization", "Bearer login-token")
+		if _, err := transport.RoundTrip(req); err != nil || sent != "Bearer login-token" {
+			t.Errorf("%s sent %q, %v", auth, sent, err)
+		}
+	}
+}
+
+func TestScanAuthWithLogin(t *testing.T) {
+	server := &loginServer{expiry: 100, pages: map[string]bool{"/admin": true}}
+	login := writeFile(t, "login.txt", "POST /login HTTP/1.1\r\nHost: app.example.org\r\n\r\nuser=a")
+	wordlist := writeFile(t, "words.txt", "a\nadmin\nb\n")
+	got := scan(t, server.ServeHTTP, Options{
+		TargetURL:        "SERVER",
+		WordlistFile:     wordlist,
+		LoginRequestFile: login,
+		LoginExtract:     "json:$.data.token",
+		Auth:             "basic:proxy:secret",
+		Headers:          []string{"Host: app.example.org"},
+		NoRecursion:      true,
+	})
+	// The login token is sent rather than the Basic credentials.
+	if strings.Join(got, " ") != "/admin" || server.logins != 1 {
+		t.Errorf("found %v after %d logins", got, server.logins)
+	}
+}
+
+func TestScanNTLMAuth(t *testing.T) {
+	serverChallenge := mustHex(t, "0123456789abcdef")
+	// NTLM authenticates a connection, told apart by its remote address.
+	var authenticated sync.Map
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := authenticated.Load(r.RemoteAddr); ok {
+			softNotFound(w, r)
+			return
+		}
+		if a := r.Header.Get("Authorization"); strings.HasPrefix(a, "Negotiate ") {
+			msg, _ := base64.StdEncoding.DecodeString(a[len("Negotiate "):])
+			switch binary.LittleEndian.Uint32(msg[8:]) {
+			case 1:
+				challenge := ntlmChallenge(serverChallenge, []byte{0, 0, 0, 0})
+				w.Header().Set("WWW-Authenticate", "Negotiate "+base64.StdEncoding.EncodeToString(challenge))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			case 3:
+				nt, _ := ntlmField(msg, 20)
+				mac := hmac.New(md5.New, ntowfv2("bob", "Secret1", "CORP"))
+				mac.Write(serverChallenge)
+				mac.Write(nt[16:])
+				if bytes.Equal(mac.Sum(nil), nt[:16]) {
+					authenticated.Store(r.RemoteAddr, true)
+					softNotFound(w, r)
+					return
+				}
+			}
+		}
+		w.Header().Add("WWW-Authenticate", "Negotiate")
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	wordlist := writeFile(t, "words.txt", "a\nb\nadmin\nc\nd\n")
+	got := scan(t, handler, Options{WordlistFile: wordlist, NoRecursion: true, NoCalibration: true, Auth: `ntlm:CORP\bob:Secret1`, Matchers: []string{"regex:admin panel"}})
+	if strings.Join(got, " ") != "/admin" {
+		t.Errorf("found %v with NTLM authentication", got)
+	}
+}
+
+func TestReplayClientSingleNTLMConnection(t *testing.T) {
+	client := newReplayClient(Options{ReplayProxy: "http://127.0.0.1:8080", Auth: "ntlm:bob:pw", Threads: 20}, nil)
+	transport, ok := client.Transport.(*ntlmTransport)
+	if !ok {
+		t.Fatalf("transport is %T", client.Transport)
+	}
+	if cap(transport.pool) != 1 {
+		t.Errorf("replay NTLM pool of %d transports", cap(transport.pool))
+	}
+	if scan := newClientTransport(Options{Auth: "ntlm:bob:pw", Threads: 20}, nil).(*ntlmTransport); cap(scan.pool) != 20 {
+		t.Errorf("scan NTLM pool of %d transports", cap(scan.pool))
+	}
+}
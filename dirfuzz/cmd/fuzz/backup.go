@@ -0,0 +1,168 @@
+package fuzz
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Placeholders of backup templates. {file} is the name of a found file,
+// {base} the name without its extension and {ext} the extension without the
+// dot. {dir} is the name of a found directory.
+const (
+	placeholderFile = "{file}"
+	placeholderBase = "{base}"
+	placeholderExt  = "{ext}"
+	placeholderDir  = "{dir}"
+)
+
+// DefaultBackupTemplates are the backup and temporary file variants probed
+// when no template file is given, as "TYPE PATTERN" lines.
+var DefaultBackupTemplates = []string{
+	"editor {file}~",
+	"autosave %23{file}%23",
+	"swap .{file}.swp",
+	"swap .{file}.swo",
+	"backup {file}.bak",
+	"backup {base}.bak",
+	"backup {file}.backup",
+	"old {file}.old",
+	"orig {file}.orig",
+	"save {file}.save",
+	"copy {file}.1",
+	"copy {file}.copy",
+	"copy {base}_copy.{ext}",
+	"temp {file}.tmp",
+	"archive {dir}.zip",
+	"archive {dir}.tar.gz",
+	"archive {dir}.tgz",
+	"archive {dir}.tar",
+	"archive {dir}.rar",
+	"archive {dir}.7z",
+}
+
+// BackupTemplate turns the name of a found file or directory into the name
+// of a backup or temporary file, such as "{file}.bak". Templates with {dir}
+// apply to directories, the others to files.
+type BackupTemplate struct {
+	// Type tags the hits of the template in the output, e.g. "swap".
+	Type    string
+	Pattern string
+}
+
+// ParseBackupTemplate parses a template given as "TYPE PATTERN".
+func ParseBackupTemplate(s string) (BackupTemplate, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return BackupTemplate{}, fmt.Errorf("invalid backup template %q (want \"TYPE PATTERN\")", s)
+	}
+	t := BackupTemplate{Type: fields[0], Pattern: fields[1]}
+	forFile := strings.Contains(t.Pattern, placeholderFile) || strings.Contains(t.Pattern, placeholderBase) ||
+		strings.Contains(t.Pattern, placeholderExt)
+	forDir := strings.Contains(t.Pattern, placeholderDir)
+	switch {
+	case forFile && forDir:
+		return BackupTemplate{}, fmt.Errorf("backup template %q mixes {dir} with file placeholders", s)
+	case !forFile && !forDir:
+		return BackupTemplate{}, fmt.Errorf("backup template %q has no placeholder", s)
+	case strings.Contains(t.Pattern, "/"):
+		return BackupTemplate{}, fmt.Errorf("backup template %q must not contain a slash", s)
+	}
+	return t, nil
+}
+
+// BackupTemplates are the variants probed for every found file and
+// directory.
+type BackupTemplates []BackupTemplate
+
+// ParseBackupTemplates parses the templates given as "TYPE PATTERN".
+func ParseBackupTemplates(lines []string) (BackupTemplates, error) {
+	ts := make(BackupTemplates, 0, len(lines))
+	for _, line := range lines {
+		t, err := ParseBackupTemplate(line)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+// LoadBackupTemplates reads a template file with one "TYPE PATTERN" per
+// line. Blank lines and lines starting with # are ignored.
+func LoadBackupTemplates(filename string) (BackupTemplates, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var ts BackupTemplates
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		t, err := ParseBackupTemplate(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, n, err)
+		}
+		ts = append(ts, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// backupVariant is the name of a backup file with the type of its template.
+type backupVariant struct {
+	value   string
+	variant string
+}
+
+// Variants returns the backup variants of a FUZZ value that was found. Only
+// the last path segment is replaced, so "app/config.php" yields
+// "app/config.php.bak". Templates needing an extension are skipped for
+// names without one.
+func (ts BackupTemplates) Variants(value string, dir bool) []backupVariant {
+	value = strings.TrimSuffix(value, "/")
+	prefix, name := "", value
+	if i := strings.LastIndex(value, "/"); i >= 0 {
+		prefix, name = value[:i+1], value[i+1:]
+	}
+	if name == "" || name == "." || name == ".." {
+		return nil
+	}
+	ext := strings.TrimPrefix(path.Ext(name), ".")
+	base := strings.TrimSuffix(name, "."+ext)
+	if ext == "" || base == "" {
+		ext, base = "", name
+	}
+
+	var variants []backupVariant
+	seen := map[string]bool{name: true}
+	for _, t := range ts {
+		if strings.Contains(t.Pattern, placeholderDir) != dir {
+			continue
+		}
+		if ext == "" && strings.Contains(t.Pattern, placeholderExt) {
+			continue
+		}
+		v := strings.NewReplacer(
+			placeholderFile, name,
+			placeholderBase, base,
+			placeholderExt, ext,
+			placeholderDir, name,
+		).Replace(t.Pattern)
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		variants = append(variants, backupVariant{value: prefix + v, variant: t.Type})
+	}
+	return variants
+}
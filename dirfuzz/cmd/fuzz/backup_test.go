@@ -0,0 +1,157 @@
+package fuzz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseBackupTemplate(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+	}{
+		{"backup {file}.bak", true},
+		{"  copy   {base}_copy.{ext} ", true},
+		{"archive {dir}.zip", true},
+		{"{file}.bak", false},
+		{"backup {file}.bak extra", false},
+		{"backup file.bak", false},
+		{"mixed {dir}-{file}", false},
+		{"nested old/{file}", false},
+	}
+	for _, tt := range tests {
+		_, err := ParseBackupTemplate(tt.line)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseBackupTemplate(%q) = %v", tt.line, err)
+		}
+	}
+	if _, err := ParseBackupTemplates(DefaultBackupTemplates); err != nil {
+		t.Errorf("default templates: %v", err)
+	}
+}
+
+func TestLoadBackupTemplates(t *testing.T) {
+	path := writeFile(t, "templates.txt", "# editors\nvim .{file}.swp\n\nbackup {file}.bak\n")
+	ts, err := LoadBackupTemplates(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) != 2 || ts[0] != (BackupTemplate{"vim", ".{file}.swp"}) {
+		t.Errorf("loaded %v", ts)
+	}
+
+	path = writeFile(t, "bad.txt", "backup {file}.bak\nbroken\n")
+	if _, err := LoadBackupTemplates(path); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("error %v, want the line number", err)
+	}
+}
+
+func TestBackupVariants(t *testing.T) {
+	ts, err := ParseBackupTemplates([]string{
+		"backup {file}.bak",
+		"backup {base}.bak",
+		"copy {base}_copy.{ext}",
+		"editor {file}~",
+		"archive {dir}.zip",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value string
+		dir   bool
+		want  string
+	}{
+		{"config.php", false, "config.php.bak:backup config.bak:backup config_copy.php:copy config.php~:editor"},
+		{"app/config.php", false, "app/config.php.bak:backup app/config.bak:backup app/config_copy.php:copy app/config.php~:editor"},
+		// Without an extension {base} is the name and {ext} templates are
+		// skipped; duplicates are probed once.
+		{"README", false, "README.bak:backup README~:editor"},
+		{".htaccess", false, ".htaccess.bak:backup .htaccess~:editor"},
+		{"uploads/", true, "uploads.zip:archive"},
+		{"static/uploads", true, "static/uploads.zip:archive"},
+		{"..", false, ""},
+		{"", false, ""},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, v := range ts.Variants(tt.value, tt.dir) {
+			got = append(got, v.value+":"+v.variant)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("Variants(%q, %v) = %q, want %q", tt.value, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestNewBackupTemplates(t *testing.T) {
+	if ts, err := (&Options{}).newBackupTemplates(); ts != nil || err != nil {
+		t.Errorf("without backups: %v, %v", ts, err)
+	}
+	ts, err := (&Options{Backups: true}).newBackupTemplates()
+	if err != nil || len(ts) != len(DefaultBackupTemplates) {
+		t.Errorf("default templates: %d, %v", len(ts), err)
+	}
+	path := writeFile(t, "templates.txt", "backup {file}.bak\n")
+	ts, err = (&Options{BackupTemplateFile: path}).newBackupTemplates()
+	if err != nil || len(ts) != 1 {
+		t.Errorf("template file: %v, %v", ts, err)
+	}
+}
+
+func TestScanProbesBackups(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.php", "/index.php.bak", "/.index.php.swp":
+			w.Write([]byte("<?php echo 1; ?>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(handler))
+	defer srv.Close()
+	wordlist := writeFile(t, "words.txt", "index.php\nmissing.php\n")
+	s, err := NewScanner(Options{TargetURL: srv.URL, WordlistFile: wordlist, Backups: true, Threads: 2, Timeout: 5, NoRecursion: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.debugFunc = func(string) {}
+	results, err := s.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	variants := make(map[string]string)
+	for _, r := range results {
+		variants[strings.TrimPrefix(r.URL, srv.URL)] = r.Variant
+	}
+	want := map[string]string{"/index.php": "", "/index.php.bak": "backup", "/.index.php.swp": "swap"}
+	if len(variants) != len(want) {
+		t.Fatalf("found %v, want %v", variants, want)
+	}
+	for path, variant := range want {
+		if got, ok := variants[path]; !ok || got != variant {
+			t.Errorf("%s has variant %q, want %q", path, got, variant)
+		}
+	}
+}
+
+func TestScanProbesDirectoryBackupsWithoutRecursion(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/site":
+			http.Redirect(w, r, "/site/", http.StatusMovedPermanently)
+		case "/site.zip":
+			w.Write([]byte("PK"))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+	wordlist := writeFile(t, "words.txt", "site\nmissing\n")
+	got := scan(t, handler, Options{WordlistFile: wordlist, Backups: true, NoRecursion: true})
+	if strings.Join(got, " ") != "/site /site.zip" {
+		t.Errorf("found %v", got)
+	}
+}
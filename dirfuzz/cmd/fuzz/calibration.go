@@ -0,0 +1,230 @@
+package fuzz
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// calibrationSamples is the number of random paths requested per payload
+// kind when calibrating a target. At least minCalibrationSamples responses
+// are needed to tell the stable features of a soft-404 from the volatile
+// ones.
+const (
+	calibrationSamples    = 3
+	minCalibrationSamples = 2
+)
+
+// calibration holds the soft-404 fingerprints of a target, keyed by payload
+// kind: "" for bare words, "/" for directories and the extension otherwise.
+type calibration struct {
+	fingerprints map[string]*fingerprint
+	elapsed      time.Duration // total time of the calibration requests
+	requests     int
+}
+
+// fingerprint describes the response to a path that does not exist.
+// Features that differ between the calibration samples are marked unstable
+// and ignored when matching.
+type fingerprint struct {
+	status   int
+	location string
+	size     int64 // -1 if unstable
+	words    int   // -1 if unstable
+	lines    int   // -1 if unstable
+	hash     uint64
+	hashed   bool // false if unstable
+}
+
+// calibrate requests random paths below the target for every payload kind
+// and fingerprints the responses that are not plain 404s.
+func (s *Scanner) calibrate(t target) *calibration {
+	c := &calibration{fingerprints: make(map[string]*fingerprint)}
+
+	kinds := []string{""}
+	if s.recursive {
+		kinds = append(kinds, "/")
+	}
+	if s.expansionFactor() > 1 {
+		kinds = append(kinds, s.extensions...)
+	}
+	for _, kind := range kinds {
+		var samples []*fingerprint
+		for i := 0; i < calibrationSamples; i++ {
+			values := make(map[string]string, len(s.wordlists))
+			for _, list := range s.wordlists {
+				values[list.Keyword] = randomToken()
+			}
+			switch kind {
+			case "":
+			case "/":
+				values[Keyword] += "/"
+			default:
+				values[Keyword] += "." + kind
+			}
+
+			encoded := s.encode(values)
+			x, err := s.fetch(t.template.Substitute(encoded))
+			if err != nil {
+				s.debugFunc(fmt.Sprintf("[ERROR] Calibration of %s payloads at %s failed: %v", kindPattern(kind), t.url, err))
+				continue
+			}
+			c.elapsed += x.elapsed
+			c.requests++
+			samples = append(samples, newFingerprint(x.resp, x.body, values, encoded))
+		}
+
+		fp := mergeFingerprints(samples)
+		if fp == nil || fp.status == http.StatusNotFound {
+			continue
+		}
+		c.fingerprints[kind] = fp
+		s.debugFunc(fmt.Sprintf("[INFO] Filtering soft-404 responses to %s payloads at %s: %s", kindPattern(kind), t.url, fp))
+	}
+
+	return c
+}
+
+// matches reports whether the response to the payload matches the soft-404
+// fingerprint of its kind. A nil calibration matches nothing.
+func (c *calibration) matches(p payload, resp *http.Response, body []byte) bool {
+	if c == nil {
+		return false
+	}
+	fp, ok := c.fingerprints[c.kind(p.values[Keyword])]
+	if !ok {
+		return false
+	}
+	return fp.matches(newFingerprint(resp, body, p.values, p.encoded))
+}
+
+// estimate returns the time the given number of requests should take with
+// the given number of threads, based on the latency seen while calibrating.
+// It returns 0 if no calibration request succeeded.
+func (c *calibration) estimate(requests int64, threads int) time.Duration {
+	if c == nil || c.requests == 0 || threads < 1 {
+		return 0
+	}
+	average := c.elapsed / time.Duration(c.requests)
+	return average * time.Duration(requests) / time.Duration(threads)
+}
+
+// kind returns the payload kind of a value, falling back to bare words for
+// extensions that were not calibrated.
+func (c *calibration) kind(value string) string {
+	if strings.HasSuffix(value, "/") {
+		return "/"
+	}
+	ext := strings.TrimPrefix(path.Ext(value), ".")
+	if _, ok := c.fingerprints[ext]; ok {
+		return ext
+	}
+	return ""
+}
+
+// newFingerprint fingerprints the response to the given payload values, as
+// given and as encoded. The values are removed from the body and the
+// redirect location before hashing, so pages reflecting the request still
+// compare equal.
+func newFingerprint(resp *http.Response, body []byte, values ...map[string]string) *fingerprint {
+	normalized := body
+	location := resp.Header.Get("Location")
+	for _, vs := range values {
+		for _, value := range vs {
+			if value == "" {
+				continue
+			}
+			normalized = bytes.ReplaceAll(normalized, []byte(value), nil)
+			location = strings.ReplaceAll(location, value, "")
+		}
+	}
+	h := fnv.New64a()
+	h.Write(normalized)
+
+	return &fingerprint{
+		status:   resp.StatusCode,
+		location: location,
+		size:     int64(len(body)),
+		words:    CountWords(body),
+		lines:    CountLines(body),
+		hash:     h.Sum64(),
+		hashed:   true,
+	}
+}
+
+// mergeFingerprints combines the samples of a payload kind. It returns nil if
+// the samples disagree on status or redirect location, since the target then
+// does not answer non-existent paths consistently, or if there are too few
+// samples to tell which features vary.
+func mergeFingerprints(samples []*fingerprint) *fingerprint {
+	if len(samples) < minCalibrationSamples {
+		return nil
+	}
+
+	fp := *samples[0]
+	for _, sample := range samples[1:] {
+		if sample.status != fp.status || sample.location != fp.location {
+			return nil
+		}
+		if sample.size != fp.size {
+			fp.size = -1
+		}
+		if sample.words != fp.words {
+			fp.words = -1
+		}
+		if sample.lines != fp.lines {
+			fp.lines = -1
+		}
+		if sample.hash != fp.hash {
+			fp.hashed = false
+		}
+	}
+	return &fp
+}
+
+// matches reports whether a response fingerprint matches the calibrated one:
+// same status and redirect location, and the same body by hash, by size, or
+// by word and line count.
+func (fp *fingerprint) matches(other *fingerprint) bool {
+	if other.status != fp.status || other.location != fp.location {
+		return false
+	}
+	switch {
+	case fp.hashed && other.hash == fp.hash:
+		return true
+	case fp.size >= 0 && other.size == fp.size:
+		return true
+	case fp.words >= 0 && fp.lines >= 0 && other.words == fp.words && other.lines == fp.lines:
+		return true
+	}
+	return false
+}
+
+func (fp *fingerprint) String() string {
+	return fmt.Sprintf("status=%d size=%d words=%d lines=%d location=%q",
+		fp.status, fp.size, fp.words, fp.lines, fp.location)
+}
+
+// kindPattern describes the paths of a payload kind in log messages.
+func kindPattern(kind string) string {
+	switch kind {
+	case "":
+		return "*"
+	case "/":
+		return "*/"
+	}
+	return "*." + kind
+}
+
+// randomToken returns a random path segment that is unlikely to exist.
+func randomToken() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
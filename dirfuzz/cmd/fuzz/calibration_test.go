@@ -0,0 +1,86 @@
+package fuzz
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMergeFingerprints(t *testing.T) {
+	a := &fingerprint{status: 200, size: 10, words: 2, lines: 1, hash: 1, hashed: true}
+	b := &fingerprint{status: 200, size: 12, words: 2, lines: 1, hash: 2, hashed: true}
+	redirect := &fingerprint{status: 302, location: "/login"}
+
+	if fp := mergeFingerprints([]*fingerprint{a}); fp != nil {
+		t.Errorf("merged a single sample into %v", fp)
+	}
+	if fp := mergeFingerprints([]*fingerprint{a, redirect}); fp != nil {
+		t.Errorf("merged samples of different statuses into %v", fp)
+	}
+	fp := mergeFingerprints([]*fingerprint{a, b, a})
+	if fp == nil || fp.size != -1 || fp.words != 2 || fp.lines != 1 || fp.hashed {
+		t.Fatalf("merged into %v, want the size and hash unstable", fp)
+	}
+	if !fp.matches(&fingerprint{status: 200, size: 99, words: 2, lines: 1}) {
+		t.Error("response with the same words and lines not matched")
+	}
+	if fp.matches(&fingerprint{status: 200, size: 10, words: 3, lines: 1}) {
+		t.Error("response with other words matched")
+	}
+	if fp.matches(&fingerprint{status: 404, words: 2, lines: 1}) {
+		t.Error("response with another status matched")
+	}
+}
+
+func TestNewFingerprintIgnoresReflectedPayload(t *testing.T) {
+	resp := func(path string) (*http.Response, []byte) {
+		r := &http.Response{StatusCode: 302, Header: http.Header{"Location": {"/login?next=" + path}}}
+		return r, []byte("<p>" + path + " was not found</p>")
+	}
+	r1, body1 := resp("abc")
+	r2, body2 := resp("defghi")
+	fp1 := newFingerprint(r1, body1, map[string]string{Keyword: "abc"})
+	fp2 := newFingerprint(r2, body2, map[string]string{Keyword: "defghi"})
+	if fp1.hash != fp2.hash || fp1.location != fp2.location {
+		t.Errorf("fingerprints %v and %v differ", fp1, fp2)
+	}
+}
+
+// softNotFound answers every unknown path with a 200 page reflecting it.
+func softNotFound(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/admin" {
+		w.Write([]byte("the admin panel"))
+		return
+	}
+	fmt.Fprintf(w, "<html>sorry, %s was not found on this branded page</html>", r.URL.Path)
+}
+
+func TestScanFiltersSoftNotFound(t *testing.T) {
+	wordlist := writeFile(t, "words.txt", "admin\nlogin\nconfig\n")
+	got := scan(t, softNotFound, Options{WordlistFile: wordlist, NoRecursion: true})
+	if strings.Join(got, " ") != "/admin" {
+		t.Errorf("found %v, want [/admin]", got)
+	}
+}
+
+func TestScanSkipsCalibrationWithoutSamples(t *testing.T) {
+	random := regexp.MustCompile(`^/[0-9a-f]{24}$`)
+	var calibrations int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		// Only the first calibration request gets an answer.
+		if random.MatchString(r.URL.Path) && atomic.AddInt32(&calibrations, 1) > 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		softNotFound(w, r)
+	}
+	wordlist := writeFile(t, "words.txt", "admin\nlogin\n")
+	got := scan(t, handler, Options{WordlistFile: wordlist, NoRecursion: true})
+	if strings.Join(got, " ") != "/admin /login" {
+		t.Errorf("found %v, want soft-404s kept without calibration", got)
+	}
+}
@@ -2,40 +2,524 @@ package fuzz
 
 import (
 	"bufio"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/your-username/dirfuzz/wordlist"
+)
+
+// 去重方式
+const (
+	// DedupeSet 用单词的64位哈希去重，内存占用与单词长度无关。
+	// 两个不同单词哈希相同的概率极小（一千万个单词约为百万分之三），
+	// 此时后一个单词会被误丢
+	DedupeSet = "set"
+	// DedupeBloom 用布隆过滤器去重，每个单词约占2字节内存，
+	// 但会以约0.1%的概率误丢单词
+	DedupeBloom = "bloom"
+	// DedupeNone 不去重
+	DedupeNone = "none"
 )
 
-// Dictionary是一个包含字典列表的结构
+// Stdin 表示从标准输入读取字典
+const Stdin = "-"
+
+// bloomFalsePositiveRate 是布隆过滤器的目标误判率
+const bloomFalsePositiveRate = 0.001
+
+// streamBloomCapacity 是条目数未知（标准输入尚未读完）时布隆过滤器
+// 按其设计的单词数，约占30MB内存
+const streamBloomCapacity = 1 << 24
+
+// Dictionary是一个流式读取的字典。单词不会全部读入内存，
+// 而是每次遍历时从来源重新读取。来源可以是普通文件、压缩文件、
+// zip或tar归档（可用"归档:模式"选择条目）、包含.txt文件的目录、
+// 标准输入或内置字典（"builtin:名称"）。
 type Dictionary struct {
-	words []string
+	files     []string
+	dedupe    string
+	rules     Mutations
+	stdin     *stdinSpool // 来源为标准输入时不为nil
+	generator bool        // 来源为生成器时为true
+
+	lines     int64
+	linesOnce sync.Once
+	linesErr  error
+
+	index     seenSet
+	indexOnce sync.Once
 }
 
-// NewDictionary创建一个新的字典结构
-func NewDictionary(filename string) (*Dictionary, error) {
-	// 打开字典文件
-	file, err := os.Open(filename)
+// NewDictionary创建一个新的字典结构，默认用DedupeSet去重。
+// 标准输入只能读取一次，因此第一次遍历时边读边把内容保存下来，
+// 之后的遍历重读保存的内容，Close时删除。
+func NewDictionary(source string) (*Dictionary, error) {
+	d := &Dictionary{dedupe: DedupeSet}
+
+	// 内置字典
+	if list, ok := wordlist.IsBuiltin(source); ok {
+		if _, err := wordlist.Describe(list); err != nil {
+			return nil, err
+		}
+		d.files = []string{source}
+		return d, nil
+	}
+
+	// 生成器的载荷数不必遍历即可得到
+	if isGenerator(source) {
+		g, err := parseGenerator(source)
+		if err != nil {
+			return nil, err
+		}
+		d.files = []string{source}
+		d.generator = true
+		d.linesOnce.Do(func() { d.lines = g.count })
+		return d, nil
+	}
+
+	// 归档可以用"归档:模式"选择条目
+	name, _ := splitArchivePath(source)
+	if name == Stdin {
+		d.files = []string{source}
+		d.stdin = &stdinSpool{source: source, input: os.Stdin}
+		return d, nil
+	}
+
+	info, err := os.Stat(name)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	if !info.IsDir() {
+		d.files = []string{source}
+		return d, nil
+	}
+
+	// 目录中的所有.txt文件（包括压缩的.txt文件）按顺序组成一个字典
+	err = filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := strings.ToLower(path)
+		for _, ext := range compressedExts {
+			name = strings.TrimSuffix(name, ext)
+		}
+		if info.Mode().IsRegular() && filepath.Ext(name) == ".txt" {
+			d.files = append(d.files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// SetDedupe设置去重方式：DedupeSet、DedupeBloom或DedupeNone，
+// 空字符串表示DedupeSet
+func (d *Dictionary) SetDedupe(dedupe string) error {
+	if err := validateDedupe(dedupe); err != nil {
+		return err
+	}
+	if dedupe == "" {
+		dedupe = DedupeSet
+	}
+	d.dedupe = dedupe
+	return nil
+}
 
-	// 读取字典文件并存储在words切片中
-	scanner := bufio.NewScanner(file)
-	var words []string
-	for scanner.Scan() {
-		words = append(words, scanner.Text())
+// Close删除标准输入保存下来的内容
+func (d *Dictionary) Close() error {
+	if d.stdin == nil {
+		return nil
 	}
+	return d.stdin.close()
+}
 
-	// 创建字典结构并返回
-	return &Dictionary{words}, nil
+// SetRules设置变形规则，每个单词会被替换为规则生成的所有候选词
+func (d *Dictionary) SetRules(rules Mutations) {
+	d.rules = rules
 }
 
-// Has返回字典中是否存在指定的单词
+// Iterate返回一个从头遍历字典的迭代器。生成器的载荷本身不重复，
+// 因此不去重，也不必为其保存哈希。
+func (d *Dictionary) Iterate() *Iterator {
+	it := d.iterator(d.rules)
+	switch {
+	case d.generator, d.dedupe == DedupeNone:
+	case d.dedupe == DedupeBloom:
+		it.seen = d.newBloomFilter()
+	default:
+		it.seen = make(hashSet)
+	}
+	return it
+}
+
+// Lines返回字典的条目数（不含空行和注释行，含重复条目）。
+// 结果只统计一次，不会把字典读入内存。
+func (d *Dictionary) Lines() (int64, error) {
+	d.linesOnce.Do(func() {
+		it := d.iterator(nil)
+		defer it.Close()
+		for it.Next() {
+			d.lines++
+		}
+		d.linesErr = it.Err()
+	})
+	return d.lines, d.linesErr
+}
+
+// Size估计字典经过变形规则后的单词数上限
+func (d *Dictionary) Size() (int64, error) {
+	lines, err := d.Lines()
+	if err != nil {
+		return 0, err
+	}
+	return d.rules.Estimate(lines), nil
+}
+
+// streaming返回字典是否为尚未读完的标准输入，此时得到条目数需要先读完它
+func (d *Dictionary) streaming() bool {
+	return d.stdin != nil && !d.stdin.complete()
+}
+
+// newBloomFilter按字典的大小创建布隆过滤器，不为此读完标准输入
+func (d *Dictionary) newBloomFilter() *bloomFilter {
+	size := int64(streamBloomCapacity)
+	if !d.streaming() {
+		if n, err := d.Size(); err == nil {
+			size = n
+		}
+	}
+	return newBloomFilter(size, bloomFalsePositiveRate)
+}
+
+// iterator返回一个应用指定规则、不去重的迭代器
+func (d *Dictionary) iterator(rules Mutations) *Iterator {
+	it := &Iterator{files: d.files, rules: rules, open: OpenPayloadFile}
+	if d.stdin != nil {
+		it.open = d.stdin.open
+	}
+	return it
+}
+
+// Has返回字典中是否存在指定的单词。第一次调用时遍历字典建立哈希索引，
+// 之后每次查询为O(1)。使用布隆过滤器去重时索引也是布隆过滤器，
+// 可能误报存在。
 func (d *Dictionary) Has(word string) bool {
-	for _, w := range d.words {
-		if w == word {
+	d.indexOnce.Do(func() {
+		var index seenSet = make(hashSet)
+		if d.dedupe == DedupeBloom {
+			index = d.newBloomFilter()
+		}
+		it := d.iterator(d.rules)
+		defer it.Close()
+		for it.Next() {
+			index.add(it.Word())
+		}
+		d.index = index
+	})
+	return d.index.has(word)
+}
+
+// Iterator逐个返回字典中的单词，跳过空行、注释行和已出现过的单词。
+// 设置了变形规则时返回每个单词的所有候选词。
+type Iterator struct {
+	files   []string
+	open    func(name string) (io.ReadCloser, error)
+	file    io.Closer
+	scanner *bufio.Scanner
+	rules   Mutations
+	pending []string // 当前单词尚未返回的候选词
+	seen    seenSet
+	word    string
+	err     error
+}
+
+// ParseEntry返回字典中一行的条目：去除首尾空白，
+// 空行和以#开头的注释行不是条目。
+func ParseEntry(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", false
+	}
+	return line, true
+}
+
+// Next前进到下一个单词，之后可以通过Word获取。
+// 到达字典末尾或出错时返回false。
+func (it *Iterator) Next() bool {
+	for it.err == nil {
+		// 返回当前单词的候选词
+		for len(it.pending) > 0 {
+			word := it.pending[0]
+			it.pending = it.pending[1:]
+			if it.seen != nil && !it.seen.add(word) {
+				continue
+			}
+			it.word = word
 			return true
 		}
+
+		if it.scanner == nil {
+			if len(it.files) == 0 {
+				return false
+			}
+			// 打开字典文件
+			var r io.ReadCloser
+			r, it.err = it.open(it.files[0])
+			if it.err != nil {
+				return false
+			}
+			it.files = it.files[1:]
+			it.file = r
+			it.scanner = bufio.NewScanner(r)
+		}
+
+		// 读取字典文件
+		for it.scanner.Scan() {
+			// 跳过空行和注释行
+			line, ok := ParseEntry(it.scanner.Text())
+			if !ok {
+				continue
+			}
+
+			// 应用变形规则
+			if len(it.rules) > 0 {
+				it.pending = it.rules.Apply(line)
+				if len(it.pending) == 0 {
+					continue
+				}
+				break
+			}
+
+			// 跳过重复的单词
+			if it.seen != nil && !it.seen.add(line) {
+				continue
+			}
+
+			it.word = line
+			return true
+		}
+		if len(it.pending) > 0 {
+			continue
+		}
+		it.err = it.scanner.Err()
+		it.file.Close()
+		it.file, it.scanner = nil, nil
 	}
 	return false
 }
+
+// Word返回当前单词
+func (it *Iterator) Word() string {
+	return it.word
+}
+
+// Err返回遍历时遇到的第一个错误
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close关闭正在读取的文件
+func (it *Iterator) Close() error {
+	if it.file == nil {
+		return nil
+	}
+	err := it.file.Close()
+	it.file, it.scanner = nil, nil
+	return err
+}
+
+// stdinSpool保存从标准输入读到的原始数据。标准输入只能读取一次，
+// 第一次遍历直接读取它，同时把读到的数据追加到临时文件；之后的遍历
+// 先重读临时文件，读到末尾后再接着读取标准输入中剩下的部分。
+// 多个遍历可以同时进行。
+type stdinSpool struct {
+	source string    // "-"或"-:模式"
+	input  io.Reader // 标准输入
+
+	mu   sync.Mutex
+	file *os.File // 临时文件，第一次读取时创建
+	size int64    // 临时文件中的字节数
+	err  error    // 读取标准输入遇到的错误，读完时为io.EOF
+	buf  []byte
+}
+
+// open从头读取标准输入，像OpenPayloadFile一样解压并选择归档条目
+func (s *stdinSpool) open(string) (io.ReadCloser, error) {
+	_, pattern := splitArchivePath(s.source)
+	rc, err := openPayload(&spoolReader{spool: s}, nil, pattern, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payloads from stdin: %v", err)
+	}
+	return rc, nil
+}
+
+// complete返回标准输入是否已经读完
+func (s *stdinSpool) complete() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err != nil
+}
+
+// fill确保临时文件中至少有off+1个字节，除非标准输入已经读完或出错。
+// 返回临时文件当前的字节数和读取标准输入遇到的错误。
+func (s *stdinSpool) fill(off int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for off >= s.size && s.err == nil {
+		if s.file == nil {
+			s.file, s.err = ioutil.TempFile("", "dirfuzz-stdin-")
+			if s.err != nil {
+				break
+			}
+			s.buf = make([]byte, 64*1024)
+		}
+		n, err := s.input.Read(s.buf)
+		if n > 0 {
+			if _, werr := s.file.WriteAt(s.buf[:n], s.size); werr != nil {
+				err = fmt.Errorf("failed to save stdin: %v", werr)
+			} else {
+				s.size += int64(n)
+			}
+		}
+		s.err = err
+	}
+	return s.size, s.err
+}
+
+// close删除临时文件
+func (s *stdinSpool) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	s.file.Close()
+	err := os.Remove(s.file.Name())
+	s.file = nil
+	return err
+}
+
+// spoolReader从头读取stdinSpool，需要更多数据时从标准输入读取
+type spoolReader struct {
+	spool *stdinSpool
+	off   int64
+}
+
+func (r *spoolReader) Read(p []byte) (int, error) {
+	size, err := r.spool.fill(r.off)
+	if r.off >= size {
+		return 0, err
+	}
+	if int64(len(p)) > size-r.off {
+		p = p[:size-r.off]
+	}
+	// 临时文件只会追加，并发的ReadAt和WriteAt互不影响
+	n, err := r.spool.file.ReadAt(p, r.off)
+	r.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// validateDedupe检查去重方式是否有效
+func validateDedupe(dedupe string) error {
+	switch dedupe {
+	case "", DedupeSet, DedupeBloom, DedupeNone:
+		return nil
+	}
+	return fmt.Errorf("invalid dedupe mode %q (want %s, %s or %s)", dedupe, DedupeSet, DedupeBloom, DedupeNone)
+}
+
+// seenSet记录已出现过的单词
+type seenSet interface {
+	// add记录单词，单词第一次出现时返回true
+	add(word string) bool
+	has(word string) bool
+}
+
+// hashSet以单词的64位FNV哈希为键，内存占用与单词长度无关。
+// 它不保存单词本身，哈希碰撞时会把不同的单词当作重复
+type hashSet map[uint64]struct{}
+
+func (s hashSet) add(word string) bool {
+	h := hashWord(word)
+	if _, ok := s[h]; ok {
+		return false
+	}
+	s[h] = struct{}{}
+	return true
+}
+
+func (s hashSet) has(word string) bool {
+	_, ok := s[hashWord(word)]
+	return ok
+}
+
+// bloomFilter是一个布隆过滤器，由一个64位哈希派生k个位置
+type bloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+// newBloomFilter创建一个可容纳n个单词、误判率为p的布隆过滤器
+func newBloomFilter(n int64, p float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+func (f *bloomFilter) add(word string) bool {
+	added := false
+	for _, pos := range f.positions(word) {
+		n, bit := pos/64, uint64(1)<<(pos%64)
+		if f.bits[n]&bit == 0 {
+			f.bits[n] |= bit
+			added = true
+		}
+	}
+	return added
+}
+
+func (f *bloomFilter) has(word string) bool {
+	for _, pos := range f.positions(word) {
+		if f.bits[pos/64]&(uint64(1)<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// positions用双重哈希返回单词对应的k个位
+func (f *bloomFilter) positions(word string) []uint64 {
+	h := hashWord(word)
+	h1, h2 := h&0xffffffff, h>>32|1
+	pos := make([]uint64, f.k)
+	for i := range pos {
+		pos[i] = (h1 + uint64(i)*h2) % f.m
+	}
+	return pos
+}
+
+// hashWord返回单词的64位FNV-1a哈希
+func hashWord(word string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(word))
+	return h.Sum64()
+}
@@ -0,0 +1,216 @@
+package fuzz
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// words returns every word of the dictionary.
+func words(t *testing.T, d *Dictionary) []string {
+	t.Helper()
+	it := d.Iterate()
+	defer it.Close()
+	var got []string
+	for it.Next() {
+		got = append(got, it.Word())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// stdinDictionary returns a dictionary reading r as stdin.
+func stdinDictionary(t *testing.T, source string, r io.Reader) *Dictionary {
+	t.Helper()
+	d, err := NewDictionary(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.stdin.input = r
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestDictionaryDedupe(t *testing.T) {
+	path := writeFile(t, "words.txt", "admin\n# comment\n\n  login  \nadmin\nbackup\nlogin\n")
+	tests := []struct {
+		dedupe string
+		want   string
+	}{
+		{"", "admin login backup"},
+		{DedupeSet, "admin login backup"},
+		{DedupeBloom, "admin login backup"},
+		{DedupeNone, "admin login admin backup login"},
+	}
+	for _, tt := range tests {
+		d, err := NewDictionary(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.SetDedupe(tt.dedupe); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(words(t, d), " "); got != tt.want {
+			t.Errorf("dedupe %q read %q, want %q", tt.dedupe, got, tt.want)
+		}
+		// Duplicates are counted.
+		if n, err := d.Lines(); n != 5 || err != nil {
+			t.Errorf("Lines() = %d, %v, want 5", n, err)
+		}
+	}
+
+	d, _ := NewDictionary(path)
+	if err := d.SetDedupe("exact"); err == nil {
+		t.Error("SetDedupe accepted an unknown mode")
+	}
+}
+
+func TestDictionaryDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeBytes(t, dir, "a.txt", []byte("admin\n"))
+	writeBytes(t, dir, "b.txt.gz", gzipBytes(t, []byte("backup\n")))
+	writeBytes(t, dir, "c.json", []byte("{}\n"))
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeBytes(t, dir, "sub/d.TXT", []byte("config\n"))
+
+	d, err := NewDictionary(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(words(t, d), " "); got != "admin backup config" {
+		t.Errorf("read %q from the directory", got)
+	}
+	if _, err := NewDictionary(filepath.Join(dir, "missing")); err == nil {
+		t.Error("NewDictionary accepted a missing file")
+	}
+}
+
+func TestDictionaryRules(t *testing.T) {
+	path := writeFile(t, "words.txt", "Admin\nadmin\nlogin\n")
+	// Each rule makes its own candidates, duplicates are dropped.
+	rules, err := ParseMutations([]string{"l", "A{1,2}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDictionary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetRules(rules)
+	if got := strings.Join(words(t, d), " "); got != "admin Admin1 Admin2 admin1 admin2 login login1 login2" {
+		t.Errorf("read %q with rules", got)
+	}
+	if n, err := d.Size(); n != 9 || err != nil {
+		t.Errorf("Size() = %d, %v, want 9", n, err)
+	}
+	if !d.Has("login2") || d.Has("Admin") {
+		t.Error("Has() does not see the rule candidates")
+	}
+}
+
+func TestDictionaryGenerator(t *testing.T) {
+	d, err := NewDictionary("range:1-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.streaming() {
+		t.Error("a generator is a stream")
+	}
+	if n, err := d.Lines(); n != 3 || err != nil {
+		t.Errorf("Lines() = %d, %v, want 3", n, err)
+	}
+	if got := strings.Join(words(t, d), " "); got != "1 2 3" {
+		t.Errorf("read %q", got)
+	}
+	// Generated payloads are distinct, so no hashes are kept for them.
+	for _, dedupe := range []string{DedupeSet, DedupeBloom} {
+		d.SetDedupe(dedupe)
+		if it := d.Iterate(); it.seen != nil {
+			t.Errorf("%s deduplicates a generator", dedupe)
+		}
+	}
+}
+
+func TestDictionaryStdinStreams(t *testing.T) {
+	r, w := io.Pipe()
+	d := stdinDictionary(t, Stdin, r)
+	// The format is sniffed from the first bytes, so the first chunk is
+	// longer than a tar header.
+	go w.Write([]byte("admin\nlogin\n#" + strings.Repeat("-", 1024) + "\n"))
+
+	// The first words are read while stdin is still open.
+	it := d.Iterate()
+	if !it.Next() || it.Word() != "admin" {
+		t.Fatalf("first word %q, %v", it.Word(), it.Err())
+	}
+	if !d.streaming() {
+		t.Error("stdin read before its end")
+	}
+	lists := []Wordlist{{Keyword: Keyword, dictionary: d}}
+	if n, err := countJobs(ModeClusterbomb, lists, false); n != -1 || err != nil {
+		t.Errorf("countJobs() = %d, %v, want -1", n, err)
+	}
+
+	go func() {
+		w.Write([]byte("admin\nbackup\n"))
+		w.Close()
+	}()
+	var got []string
+	for it.Next() {
+		got = append(got, it.Word())
+	}
+	it.Close()
+	if strings.Join(got, " ") != "login backup" || it.Err() != nil {
+		t.Errorf("read %q, %v after the first word", got, it.Err())
+	}
+
+	// Later passes replay what was read.
+	if got := strings.Join(words(t, d), " "); got != "admin login backup" {
+		t.Errorf("second pass read %q", got)
+	}
+	if n, err := countJobs(ModeClusterbomb, lists, false); n != 4 || err != nil {
+		t.Errorf("countJobs() = %d, %v, want 4", n, err)
+	}
+	if !d.Has("backup") {
+		t.Error("Has(backup) = false")
+	}
+}
+
+func TestDictionaryStdinExactCount(t *testing.T) {
+	d := stdinDictionary(t, Stdin, strings.NewReader("a\nb\n"))
+	lists := []Wordlist{{Keyword: Keyword, dictionary: d}}
+	if n, err := countJobs(ModeClusterbomb, lists, true); n != 2 || err != nil {
+		t.Errorf("countJobs() = %d, %v, want 2", n, err)
+	}
+	if got := strings.Join(words(t, d), " "); got != "a b" {
+		t.Errorf("read %q after counting", got)
+	}
+}
+
+func TestDictionaryStdinArchive(t *testing.T) {
+	data := gzipBytes(t, tarBytes(t, archiveEntry{"README", "readme\n"}, archiveEntry{"a.txt", "admin\n"}))
+	d := stdinDictionary(t, "-:*.txt", strings.NewReader(string(data)))
+	for pass := 0; pass < 2; pass++ {
+		if got := strings.Join(words(t, d), " "); got != "admin" {
+			t.Errorf("pass %d read %q from the archive", pass, got)
+		}
+	}
+}
+
+func TestDictionaryStdinClose(t *testing.T) {
+	d := stdinDictionary(t, Stdin, strings.NewReader("admin\n"))
+	words(t, d)
+	name := d.stdin.file.Name()
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("the saved stdin %s is left behind", name)
+	}
+}
@@ -0,0 +1,152 @@
+package fuzz
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// encoders are the payload encoders by name.
+var encoders = map[string]func(string) string{
+	// url percent-encodes everything but unreserved characters.
+	"url": urlEncode,
+	// doubleurl percent-encodes twice, so %2f becomes %252f.
+	"doubleurl": func(s string) string { return urlEncode(urlEncode(s)) },
+	// urlall percent-encodes every byte.
+	"urlall": func(s string) string {
+		var b strings.Builder
+		for i := 0; i < len(s); i++ {
+			fmt.Fprintf(&b, "%%%02X", s[i])
+		}
+		return b.String()
+	},
+	// unicode encodes everything but unreserved characters as %uXXXX, as
+	// understood by IIS.
+	"unicode": func(s string) string {
+		var b strings.Builder
+		for _, r := range s {
+			if r < 0x80 && isUnreserved(byte(r)) {
+				b.WriteRune(r)
+			} else if r > 0xffff {
+				fmt.Fprintf(&b, "%%u%04X%%u%04X", 0xd800+(r-0x10000)>>10, 0xdc00+(r-0x10000)&0x3ff)
+			} else {
+				fmt.Fprintf(&b, "%%u%04X", r)
+			}
+		}
+		return b.String()
+	},
+	// html escapes <, >, &, ' and " as entities, htmlall every character
+	// that is not a letter or digit as a numeric entity.
+	"html": html.EscapeString,
+	"htmlall": func(s string) string {
+		var b strings.Builder
+		for _, r := range s {
+			if r < 0x80 && isAlphanumeric(byte(r)) {
+				b.WriteRune(r)
+			} else {
+				fmt.Fprintf(&b, "&#x%x;", r)
+			}
+		}
+		return b.String()
+	},
+	"base64":    func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"base64url": func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) },
+	"hex":       func(s string) string { return hex.EncodeToString([]byte(s)) },
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	// Path separator variants, for servers that normalize them.
+	"backslash":   func(s string) string { return strings.ReplaceAll(s, "/", `\`) },
+	"doubleslash": func(s string) string { return strings.ReplaceAll(s, "/", "//") },
+	"slash2f":     func(s string) string { return strings.ReplaceAll(s, "/", "%2f") },
+	"backslash5c": func(s string) string { return strings.ReplaceAll(s, "/", "%5c") },
+	"dotslash":    func(s string) string { return strings.ReplaceAll(s, "/", "/./") },
+}
+
+// EncoderNames returns the names of the payload encoders in sorted order.
+func EncoderNames() []string {
+	names := make([]string, 0, len(encoders))
+	for name := range encoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Encoders is a chain of payload encoders applied in order.
+type Encoders struct {
+	names []string
+	funcs []func(string) string
+}
+
+// ParseEncoders parses a comma-separated encoder chain, such as
+// "url,base64".
+func ParseEncoders(spec string) (Encoders, error) {
+	var e Encoders
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		fn, ok := encoders[name]
+		if !ok {
+			return Encoders{}, fmt.Errorf("unknown encoder %q (want one of %s)", name, strings.Join(EncoderNames(), ", "))
+		}
+		e.names = append(e.names, name)
+		e.funcs = append(e.funcs, fn)
+	}
+	return e, nil
+}
+
+// Encode passes a payload through the chain.
+func (e Encoders) Encode(s string) string {
+	for _, fn := range e.funcs {
+		s = fn(s)
+	}
+	return s
+}
+
+// String returns the chain as it is parsed.
+func (e Encoders) String() string {
+	return strings.Join(e.names, ",")
+}
+
+// ParseEncoding parses an encoder chain given as "chain" or "chain:KEYWORD",
+// as in "url,base64:ID". The keyword defaults to FUZZ.
+func ParseEncoding(spec string) (string, Encoders, error) {
+	keyword := Keyword
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		keyword = spec[i+1:]
+		spec = spec[:i]
+		if !keywordPattern.MatchString(keyword) {
+			return "", Encoders{}, fmt.Errorf("invalid keyword %q in encoder chain", keyword)
+		}
+	}
+	e, err := ParseEncoders(spec)
+	if err != nil {
+		return "", Encoders{}, err
+	}
+	return keyword, e, nil
+}
+
+// urlEncode percent-encodes everything but unreserved characters. Unlike
+// url.QueryEscape it encodes spaces as %20.
+func urlEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if isUnreserved(s[i]) {
+			b.WriteByte(s[i])
+		} else {
+			fmt.Fprintf(&b, "%%%02X", s[i])
+		}
+	}
+	return b.String()
+}
+
+// isUnreserved reports whether c is an unreserved URI character.
+func isUnreserved(c byte) bool {
+	return isAlphanumeric(c) || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isAlphanumeric(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
@@ -0,0 +1,212 @@
+package fuzz
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEncoders(t *testing.T) {
+	tests := []struct {
+		chain, in, want string
+	}{
+		{"url", "a b/c?d=é~", "a%20b%2Fc%3Fd%3D%C3%A9~"},
+		{"doubleurl", "../", "..%252F"},
+		{"urlall", "a/", "%61%2F"},
+		{"unicode", "a/é😀", "a%u002F%u00E9%uD83D%uDE00"},
+		{"html", `<a href="x">&'`, "&lt;a href=&#34;x&#34;&gt;&amp;&#39;"},
+		{"htmlall", "a<b c", "a&#x3c;b&#x20;c"},
+		{"base64", "admin?", "YWRtaW4/"},
+		{"base64url", "admin?", "YWRtaW4_"},
+		{"hex", "ab", "6162"},
+		{"upper", "Admin", "ADMIN"},
+		{"lower", "Admin", "admin"},
+		{"backslash", "a/b", `a\b`},
+		{"doubleslash", "a/b", "a//b"},
+		{"slash2f", "a/b", "a%2fb"},
+		{"backslash5c", "a/b", "a%5cb"},
+		{"dotslash", "a/b", "a/./b"},
+		// Chains apply in order and ignore case and spaces.
+		{"base64, URL", "admin?", "YWRtaW4%2F"},
+		{"url,base64", "admin?", "YWRtaW4lM0Y="},
+	}
+	for _, tt := range tests {
+		e, err := ParseEncoders(tt.chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.Encode(tt.in); got != tt.want {
+			t.Errorf("%s encoded %q as %q, want %q", tt.chain, tt.in, got, tt.want)
+		}
+	}
+	if len(EncoderNames()) != len(encoders) {
+		t.Errorf("EncoderNames() = %v", EncoderNames())
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	tests := []struct {
+		spec, keyword, chain string
+		ok                   bool
+	}{
+		{"url", Keyword, "url", true},
+		{"Base64, url:ID", "ID", "base64,url", true},
+		{"url:id", "", "", false},
+		{"url,rot13", "", "", false},
+		{"url,", "", "", false},
+		{"", "", "", false},
+	}
+	for _, tt := range tests {
+		keyword, e, err := ParseEncoding(tt.spec)
+		if (err == nil) != tt.ok || keyword != tt.keyword || e.String() != tt.chain {
+			t.Errorf("ParseEncoding(%q) = %q, %q, %v", tt.spec, keyword, e, err)
+		}
+	}
+}
+
+func TestNewEncoders(t *testing.T) {
+	lists := []Wordlist{{Keyword: Keyword}, {Keyword: "ID"}}
+	o := &Options{Encodings: []string{"url", "base64:ID"}}
+	chains, err := o.newEncoders(lists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chains) != 2 || chains[Keyword].String() != "url" || chains["ID"].String() != "base64" {
+		t.Errorf("chains %v", chains)
+	}
+
+	for _, encodings := range [][]string{{"url:USER"}, {"url", "hex:FUZZ"}} {
+		o := &Options{Encodings: encodings}
+		if _, err := o.newEncoders(lists); err == nil {
+			t.Errorf("encoder chains %q accepted", encodings)
+		}
+	}
+}
+
+func TestScanEncodesPayloads(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("id") == "61646d696e" && r.URL.Query().Get("user") == "root" {
+			w.Write([]byte("ok"))
+			return
+		}
+		http.NotFound(w, r)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(handler))
+	defer srv.Close()
+	ids := writeFile(t, "ids.txt", "guest\nadmin\n")
+	users := writeFile(t, "users.txt", "root\n")
+	s, err := NewScanner(Options{
+		TargetURL:   srv.URL + "/?id=FUZZ&user=USER",
+		Wordlists:   []string{ids, users + ":USER"},
+		Encodings:   []string{"hex"},
+		Threads:     2,
+		Timeout:     5,
+		NoRecursion: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.debugFunc = func(string) {}
+	results, err := s.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("found %v", resultPaths(results))
+	}
+	// The result names the payload as read and as sent.
+	r := results[0]
+	if !strings.Contains(r.Payload, "FUZZ=admin") || !strings.Contains(r.Encoded, "FUZZ=61646d696e") || !strings.Contains(r.Encoded, "USER=root") {
+		t.Errorf("payload %q, encoded %q", r.Payload, r.Encoded)
+	}
+}
+
+// lineServer answers every request whose request line holds want with 200,
+// and all others with 404. Unlike httptest servers, it takes request
+// targets that are not valid URLs. It returns the server URL.
+func lineServer(t *testing.T, want string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					for {
+						header, err := r.ReadString('\n')
+						if err != nil {
+							return
+						}
+						if header == "\r\n" {
+							break
+						}
+					}
+					status, body := "404 Not Found", "not found"
+					if strings.Contains(line, want) {
+						status, body = "200 OK", "ok"
+					}
+					fmt.Fprintf(conn, "HTTP/1.1 %s\r\nContent-Length: %d\r\n\r\n%s", status, len(body), body)
+				}
+			}()
+		}
+	}()
+	return "http://" + l.Addr().String()
+}
+
+func TestScanEncodesPayloadsInPath(t *testing.T) {
+	tests := []struct {
+		encoding, line string
+	}{
+		{"unicode", "GET /files/admin%u0020page HTTP/1.1"},
+		{"htmlall", "GET /files/admin&#x20;page HTTP/1.1"},
+	}
+	wordlist := writeFile(t, "words.txt", "index\nadmin page\n")
+	for _, tt := range tests {
+		srv := lineServer(t, tt.line)
+		s, err := NewScanner(Options{
+			TargetURL:    srv + "/files/FUZZ",
+			WordlistFile: wordlist,
+			Encodings:    []string{tt.encoding},
+			Threads:      2,
+			Timeout:      5,
+			NoRecursion:  true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var mu sync.Mutex
+		var errors []string
+		s.debugFunc = func(msg string) {
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Contains(msg, "[ERROR]") {
+				errors = append(errors, msg)
+			}
+		}
+		results, err := s.Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || len(errors) > 0 {
+			t.Errorf("%s found %v, errors %q", tt.encoding, resultPaths(results), errors)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package fuzz
+
+import "strings"
+
+// extPlaceholder is replaced by each extension, as in dirsearch wordlists.
+const extPlaceholder = "%EXT%"
+
+// payload is a combination of keyword values after extension expansion.
+type payload struct {
+	values map[string]string
+	// encoded holds the values as sent, after the encoder chains.
+	encoded map[string]string
+	// extension is the extension appended to the FUZZ value, if any.
+	extension string
+	// variant is the type of the backup template that produced the FUZZ
+	// value, if any.
+	variant string
+}
+
+// expansion is a wordlist entry after extension expansion.
+type expansion struct {
+	value string
+	// extension is the extension that produced the value, if any.
+	extension string
+}
+
+// parseExtensions splits a comma-separated list of extensions, with or
+// without a leading dot.
+func parseExtensions(s string) []string {
+	var exts []string
+	for _, ext := range strings.Split(s, ",") {
+		ext = strings.TrimPrefix(strings.TrimSpace(ext), ".")
+		if ext != "" {
+			exts = append(exts, ext)
+		}
+	}
+	return exts
+}
+
+// expandWord returns the values to send for a wordlist entry. Entries with
+// the %EXT% placeholder produce one value per extension. Other entries
+// produce the bare word followed by one variant per extension, unless they
+// are directories, or contain a dot and noDot is set.
+func expandWord(word string, exts []string, noDot bool) []expansion {
+	if strings.Contains(word, extPlaceholder) {
+		expansions := make([]expansion, 0, len(exts))
+		for _, ext := range exts {
+			expansions = append(expansions, expansion{
+				value:     strings.ReplaceAll(word, extPlaceholder, ext),
+				extension: ext,
+			})
+		}
+		return expansions
+	}
+
+	expansions := []expansion{{value: word}}
+	if strings.HasSuffix(word, "/") || (noDot && strings.Contains(word, ".")) {
+		return expansions
+	}
+	for _, ext := range exts {
+		expansions = append(expansions, expansion{value: word + "." + ext, extension: ext})
+	}
+	return expansions
+}
+
+// expandPayloads returns the payloads to send for a combination of keyword
+// values. Only the FUZZ value is expanded with extensions.
+func expandPayloads(values map[string]string, exts []string, noDot bool) []payload {
+	word, ok := values[Keyword]
+	if !ok {
+		return []payload{{values: copyValues(values)}}
+	}
+
+	var payloads []payload
+	for _, e := range expandWord(word, exts, noDot) {
+		p := payload{values: copyValues(values), extension: e.extension}
+		p.values[Keyword] = e.value
+		payloads = append(payloads, p)
+	}
+	return payloads
+}
+
+// copyValues returns a copy of the keyword values.
+func copyValues(values map[string]string) map[string]string {
+	c := make(map[string]string, len(values))
+	for k, v := range values {
+		c[k] = v
+	}
+	return c
+}
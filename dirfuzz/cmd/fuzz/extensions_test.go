@@ -0,0 +1,88 @@
+package fuzz
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseExtensions(t *testing.T) {
+	got := parseExtensions(" php, .bak,,tar.gz ,")
+	if strings.Join(got, " ") != "php bak tar.gz" {
+		t.Errorf("parseExtensions() = %q", got)
+	}
+	if got := parseExtensions(""); got != nil {
+		t.Errorf("parseExtensions(\"\") = %q", got)
+	}
+}
+
+func TestExpandWord(t *testing.T) {
+	exts := []string{"php", "bak"}
+	tests := []struct {
+		word  string
+		noDot bool
+		want  string
+	}{
+		{"admin", false, "admin admin.php:php admin.bak:bak"},
+		{"admin/", false, "admin/"},
+		{"index.html", false, "index.html index.html.php:php index.html.bak:bak"},
+		{"index.html", true, "index.html"},
+		{"admin", true, "admin admin.php:php admin.bak:bak"},
+		// The placeholder replaces the bare word.
+		{"config.%EXT%", false, "config.php:php config.bak:bak"},
+		{"%EXT%/%EXT%", true, "php/php:php bak/bak:bak"},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, e := range expandWord(tt.word, exts, tt.noDot) {
+			if e.extension != "" {
+				got = append(got, e.value+":"+e.extension)
+			} else {
+				got = append(got, e.value)
+			}
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("expandWord(%q, noDot %v) = %q, want %q", tt.word, tt.noDot, got, tt.want)
+		}
+	}
+	if got := expandWord("a.%EXT%", nil, false); len(got) != 0 {
+		t.Errorf("placeholder without extensions made %v", got)
+	}
+}
+
+func TestExpandPayloadsOnlyExpandsFuzz(t *testing.T) {
+	values := map[string]string{Keyword: "admin", "USER": "root"}
+	payloads := expandPayloads(values, []string{"php"}, false)
+	if len(payloads) != 2 {
+		t.Fatalf("made %d payloads, want 2", len(payloads))
+	}
+	if got := fmt.Sprint(payloads[1].values); got != "map[FUZZ:admin.php USER:root]" || payloads[1].extension != "php" {
+		t.Errorf("second payload %s, extension %q", got, payloads[1].extension)
+	}
+	if values[Keyword] != "admin" {
+		t.Error("expandPayloads changed the values")
+	}
+
+	payloads = expandPayloads(map[string]string{"USER": "root"}, []string{"php"}, false)
+	if len(payloads) != 1 || payloads[0].values["USER"] != "root" {
+		t.Errorf("payloads without FUZZ: %v", payloads)
+	}
+}
+
+func TestScanExtensions(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin.php", "/config.bak", "/index.html":
+			w.Write([]byte("ok"))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+	wordlist := writeFile(t, "words.txt", "admin\nconfig.%EXT%\nindex.html\n")
+	got := scan(t, handler, Options{WordlistFile: wordlist, Extensions: "php,.bak", ExtensionsNoDot: true, NoRecursion: true})
+	want := "/admin.php /config.bak /index.html"
+	if strings.Join(got, " ") != want {
+		t.Errorf("found %v, want %s", got, want)
+	}
+}
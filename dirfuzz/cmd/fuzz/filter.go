@@ -1,263 +1,566 @@
 package fuzz
 
 import (
-    "bytes"
-    "regexp"
-    "strconv"
-    "strings"
-    "unicode"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidFilter 表示过滤规则无法解析
+var ErrInvalidFilter = errors.New("invalid filter")
 
-    "github.com/gookit/color"
+// 规则的组合方式
+const (
+	ModeOr  = "or"  // 满足任一规则
+	ModeAnd = "and" // 满足全部规则
 )
 
-// 定义一个结构体用于存储过滤规则
-type Filter struct {
-    StatusCode []int         // 状态码过滤规则
-    WordSize   []int         // 响应大小过滤规则
-    WordRegexp []*regexp.Regexp // 正则表达式过滤规则
-    WordList   []string      // 关键字过滤规则
-    IgnoreWord []string      // 忽略关键字过滤规则
+// Response 是过滤引擎判断的响应
+type Response struct {
+	Payload    string
+	StatusCode int
+	Size       int64
+	Words      int
+	Lines      int
+	Header     http.Header
+	Body       []byte
+	TTFB       time.Duration // 收到第一个字节的时间
+	Duration   time.Duration // 读完响应体的时间
 }
 
-// 新建一个过滤器对象
-func NewFilter() *Filter {
-    return &Filter{}
-}
-
-// 解析状态码过滤规则
-func (f *Filter) ParseStatus(status string) error {
-    if status == "" {
-        return nil
-    }
-
-    status = strings.ReplaceAll(status, " ", "") // 去除空格
-
-    for _, s := range strings.Split(status, ",") {
-        if strings.Contains(s, "-") { // 处理范围过滤规则，如：200-299
-            parts := strings.Split(s, "-")
-            if len(parts) != 2 {
-                return ErrInvalidFilter
-            }
+// Criterion 是对响应的一个判断条件，如 status:200-299
+type Criterion interface {
+	Match(r *Response) bool
+}
 
-            start, err := strconv.Atoi(parts[0])
-            if err != nil {
-                return ErrInvalidFilter
-            }
+// criteria 保存已注册的条件名称及其解析函数
+var criteria = make(map[string]func(value string) (Criterion, error))
 
-            end, err := strconv.Atoi(parts[1])
-            if err != nil {
-                return ErrInvalidFilter
-            }
-
-            for i := start; i <= end; i++ {
-                f.StatusCode = append(f.StatusCode, i)
-            }
-        } else { // 处理单个状态码过滤规则，如：404
-            code, err := strconv.Atoi(s)
-            if err != nil {
-                return ErrInvalidFilter
-            }
-
-            f.StatusCode = append(f.StatusCode, code)
-        }
-    }
-
-    return nil
-}
-
-// 解析响应大小过滤规则
-func (f *Filter) ParseSize(size string) error {
-    if size == "" {
-        return nil
-    }
-
-    size = strings.ReplaceAll(size, " ", "") // 去除空格
-
-    for _, s := range strings.Split(size, ",") {
-        if strings.Contains(s, "-") { // 处理范围过滤规则，如：0-500
-            parts := strings.Split(s, "-")
-            if len(parts) != 2 {
-                return ErrInvalidFilter
-            }
-
-            start, err := strconv.Atoi(parts[0])
-            if err != nil {
-                return ErrInvalidFilter
-            }
-
-            end, err := strconv.Atoi(parts[1])
-            if err != nil {
-                return ErrInvalidFilter
-            }
-
-            f.WordSize = append(f.WordSize, start, end)
-        } else { // 处理单个响应大小过滤规则，如：1000
-            size, err := strconv.Atoi(s)
-            if err != nil {
-                return ErrInvalidFilter
-            }
-
-            f.WordSize = append(f.WordSize, size)
-        }
-    }
-
-    return nil
-}
-
-// 解析正则表达式过滤规则
-func (f *Filter) ParseRegexp(regexpStr string) error {
-    if regexpStr == "" {
-        return nil
-    }
-
-    for _, s := range strings.Split(regexpStr, ",") {
-        // 处理正则表达式过滤规则，如：(?i)admin|password
-        if r, err := regexp.Compile(s); err == nil {
-            f.WordRegexp = append(f.WordRegexp, r)
-        } else {
-            return ErrInvalidFilter
-        }
-    }
-
-    return nil
-}
-
-// 解析关键字过滤规则
-func (f *Filter) ParseWordList(wordList string) error {
-    if wordList == "" {
-        return nil
-    }
-
-    f.WordList = splitString(wordList)
-
-    return nil
-}
-
-
-// 判断响应是否符合过滤规则
-func (f *Filter) FilterResponse(status int, size int, body []byte) bool {
-    // 判断状态码是否符合规则
-    if len(f.StatusCode) > 0 && !contains(f.StatusCode, status) {
-        return false
-    }
-
-    // 判断响应大小是否符合规则
-    if len(f.WordSize) > 0 && !sizeInRange(size, f.WordSize) {
-        return false
-    }
-
-    // 判断正则表达式是否符合规则
-    if len(f.WordRegexp) > 0 {
-        matched := false
-        for _, r := range f.WordRegexp {
-            if r.Match(body) {
-                matched = true
-                break
-            }
-        }
-        if !matched {
-            return false
-        }
-    }
-
-    // 判断关键字是否符合规则
-    if len(f.WordList) > 0 {
-        found := false
-        for _, word := range f.WordList {
-            if bytes.Contains(body, []byte(word)) {
-                found = true
-                break
-            }
-        }
-        if !found {
-            return false
-        }
-    }
-
-    // 判断忽略关键字是否符合规则
-    if len(f.IgnoreWord) > 0 {
-        for _, word := range f.IgnoreWord {
-            if bytes.Contains(body, []byte(word)) {
-                return false
-            }
-        }
-    }
-
-    return true
-}
-
-// 判断一个值是否在一个整数数组中
-func contains(arr []int, val int) bool {
-    for _, v := range arr {
-        if v == val {
-            return true
-        }
-    }
-    return false
-}
-
-// 判断响应大小是否在一个范围内
-func sizeInRange(size int, rangeArr []int) bool {
-    for i := 0; i < len(rangeArr); i += 2 {
-        start := rangeArr[i]
-        end := rangeArr[i+1]
-
-        if start <= size && size <= end {
-            return true
-        }
-    }
-    return false
+// RegisterCriterion 注册一个条件名称，规则中的 name:value 由 parse 解析
+func RegisterCriterion(name string, parse func(value string) (Criterion, error)) {
+	criteria[name] = parse
 }
 
-// 将字符串按照逗号分割成切片
-func splitString(s string) []string {
-    var ret []string
-    for _, v := range strings.Split(s, ",") {
-        if v != "" {
-            ret = append(ret, v)
-        }
-    }
-    return ret
+// Criteria 返回已注册的条件名称
+func Criteria() []string {
+	var names []string
+	for name := range criteria {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
-// Filter 过滤器
+// Filter 是响应的匹配/过滤引擎。匹配规则决定保留哪些响应，过滤规则决定
+// 丢弃哪些响应；此外还可以在发送请求前按黑名单或白名单跳过 payload。
 type Filter struct {
-    blacklist map[string]bool // 黑名单
-    whitelist map[string]bool // 白名单
+	Matchers   []Criterion // 匹配规则
+	Filters    []Criterion // 过滤规则
+	MatchMode  string      // 匹配规则的组合方式，默认为 or
+	FilterMode string      // 过滤规则的组合方式，默认为 or
+
+	blacklist map[string]bool // payload 黑名单
+	whitelist map[string]bool // payload 白名单
 }
 
 // NewFilter 创建一个过滤器
-func NewFilter(blacklist, whitelist []string) *Filter {
-    ret := &Filter{
-        blacklist: make(map[string]bool),
-        whitelist: make(map[string]bool),
-    }
+func NewFilter() *Filter {
+	return &Filter{
+		MatchMode:  ModeOr,
+		FilterMode: ModeOr,
+		blacklist:  make(map[string]bool),
+		whitelist:  make(map[string]bool),
+	}
+}
 
-    // 将黑名单中的元素添加到 map 中
-    for _, v := range blacklist {
-        ret.blacklist[v] = true
-    }
+// AddMatcher 添加一条匹配规则
+func (f *Filter) AddMatcher(rule string) error {
+	c, err := ParseRule(rule)
+	if err != nil {
+		return err
+	}
+	f.Matchers = append(f.Matchers, c)
+	return nil
+}
+
+// AddFilter 添加一条过滤规则
+func (f *Filter) AddFilter(rule string) error {
+	c, err := ParseRule(rule)
+	if err != nil {
+		return err
+	}
+	f.Filters = append(f.Filters, c)
+	return nil
+}
 
-    // 将白名单中的元素添加到 map 中
-    for _, v := range whitelist {
-        ret.whitelist[v] = true
-    }
+// Blacklist 将 payload 加入黑名单
+func (f *Filter) Blacklist(payloads ...string) {
+	for _, v := range payloads {
+		f.blacklist[v] = true
+	}
+}
 
-    return ret
+// Whitelist 将 payload 加入白名单
+func (f *Filter) Whitelist(payloads ...string) {
+	for _, v := range payloads {
+		f.whitelist[v] = true
+	}
 }
 
-// Match 判断 s 是否符合过滤条件
+// Match 判断 payload 是否应该被发送
 func (f *Filter) Match(s string) bool {
-    // 如果存在白名单，则只允许白名单中的元素通过
-    if len(f.whitelist) > 0 {
-        return f.whitelist[s]
-    }
-
-    // 如果存在黑名单，则只禁止黑名单中的元素通过
-    if len(f.blacklist) > 0 {
-        return !f.blacklist[s]
-    }
-
-    // 如果不存在黑名单或白名单，则允许所有元素通过
-    return true
+	// 如果存在白名单，则只允许白名单中的元素通过
+	if len(f.whitelist) > 0 {
+		return f.whitelist[s]
+	}
+
+	// 如果存在黑名单，则只禁止黑名单中的元素通过
+	return !f.blacklist[s]
+}
+
+// FilterResponse 判断响应是否应该被保留：没有匹配规则或匹配规则成立，
+// 并且过滤规则不成立
+func (f *Filter) FilterResponse(r *Response) bool {
+	if len(f.Matchers) > 0 && !combine(f.Matchers, f.MatchMode, r) {
+		return false
+	}
+	if len(f.Filters) > 0 && combine(f.Filters, f.FilterMode, r) {
+		return false
+	}
+	return true
+}
+
+// 按组合方式判断多条规则
+func combine(rules []Criterion, mode string, r *Response) bool {
+	if mode == ModeAnd {
+		return allOf(rules).Match(r)
+	}
+	return anyOf(rules).Match(r)
+}
+
+// ParseRule 解析一条规则。规则由 name:value 形式的条件组成，条件前加 !
+// 表示取反，&& 表示并且，|| 表示或者，&& 的优先级高于 ||，例如：
+//
+//	status:200-299 && !regex:(?i)not found || size:0
+//
+// 只有后面紧跟一个条件的 && 和 || 才是运算符，条件值中的其他 && 和 ||
+// 保持原样，如 regex:a||b 是一个正则表达式。
+func ParseRule(rule string) (Criterion, error) {
+	var alternatives anyOf
+	for _, group := range splitRule(rule, "||") {
+		var all allOf
+		for _, term := range splitRule(group, "&&") {
+			c, err := parseTerm(strings.TrimSpace(term))
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, c)
+		}
+		alternatives = append(alternatives, simplify(all))
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0], nil
+	}
+	return alternatives, nil
+}
+
+// 按运算符拆分规则，只在运算符后面是一个已注册的条件时拆分
+func splitRule(rule, op string) []string {
+	var parts []string
+	start := 0
+	for i := strings.Index(rule, op); i >= 0; {
+		next := i + len(op)
+		if startsTerm(rule[next:]) {
+			parts = append(parts, rule[start:i])
+			start = next
+		}
+		j := strings.Index(rule[next:], op)
+		if j < 0 {
+			break
+		}
+		i = next + j
+	}
+	return append(parts, rule[start:])
+}
+
+// 判断 s 是否以一个条件开头，如 !status:
+func startsTerm(s string) bool {
+	s = strings.TrimLeft(s, " \t!")
+	name, _, ok := strings.Cut(s, ":")
+	if !ok {
+		return false
+	}
+	_, ok = criteria[strings.ToLower(strings.TrimSpace(name))]
+	return ok
+}
+
+// 解析单个条件，如 !status:404
+func parseTerm(term string) (Criterion, error) {
+	negate := false
+	for strings.HasPrefix(term, "!") {
+		negate = !negate
+		term = strings.TrimSpace(term[1:])
+	}
+
+	name, value, ok := strings.Cut(term, ":")
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not of the form name:value", ErrInvalidFilter, term)
+	}
+	parse, ok := criteria[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown criterion %q (available: %s)", ErrInvalidFilter, name, strings.Join(Criteria(), ", "))
+	}
+	c, err := parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidFilter, term, err)
+	}
+
+	if negate {
+		return not{c}, nil
+	}
+	return c, nil
+}
+
+// 只有一个条件时不需要组合
+func simplify(all allOf) Criterion {
+	if len(all) == 1 {
+		return all[0]
+	}
+	return all
+}
+
+// allOf 在全部条件成立时成立
+type allOf []Criterion
+
+func (a allOf) Match(r *Response) bool {
+	for _, c := range a {
+		if !c.Match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// anyOf 在任一条件成立时成立
+type anyOf []Criterion
+
+func (a anyOf) Match(r *Response) bool {
+	for _, c := range a {
+		if c.Match(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// not 对条件取反
+type not struct {
+	Criterion
+}
+
+func (n not) Match(r *Response) bool {
+	return !n.Criterion.Match(r)
+}
+
+// Ranges 是一组整数范围，如 200,301-303
+type Ranges []intRange
+
+type intRange struct {
+	min, max int64
+}
+
+// ParseRanges 解析逗号分隔的整数或整数范围
+func ParseRanges(s string) (Ranges, error) {
+	s = strings.ReplaceAll(s, " ", "") // 去除空格
+
+	var ranges Ranges
+	for _, v := range splitString(s) {
+		// 处理范围，如：200-299；单个值如 404 视为 404-404
+		start, end, isRange := strings.Cut(v, "-")
+		if !isRange {
+			end = start
+		}
+		min, err := strconv.ParseInt(start, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", start)
+		}
+		max, err := strconv.ParseInt(end, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", end)
+		}
+		if max < min {
+			return nil, fmt.Errorf("invalid range %q", v)
+		}
+		ranges = append(ranges, intRange{min, max})
+	}
+	if len(ranges) == 0 {
+		return nil, errors.New("empty range")
+	}
+	return ranges, nil
+}
+
+// Contains 判断一个值是否在范围内
+func (r Ranges) Contains(n int64) bool {
+	for _, v := range r {
+		if v.min <= n && n <= v.max {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeCriterion 判断响应的某个整数属性是否在范围内
+type rangeCriterion struct {
+	ranges Ranges
+	value  func(r *Response) int64
+}
+
+func (c rangeCriterion) Match(r *Response) bool {
+	return c.ranges.Contains(c.value(r))
+}
+
+// newRangeCriterion 返回按整数属性解析范围的函数
+func newRangeCriterion(value func(r *Response) int64) func(string) (Criterion, error) {
+	return func(s string) (Criterion, error) {
+		ranges, err := ParseRanges(s)
+		if err != nil {
+			return nil, err
+		}
+		return rangeCriterion{ranges, value}, nil
+	}
+}
+
+// regexCriterion 判断响应体是否匹配正则表达式，如：(?i)admin|password
+type regexCriterion struct {
+	re *regexp.Regexp
+}
+
+func (c regexCriterion) Match(r *Response) bool {
+	return c.re.Match(r.Body)
+}
+
+// stringCriterion 判断响应体是否包含任一关键字
+type stringCriterion struct {
+	words [][]byte
+}
+
+func (c stringCriterion) Match(r *Response) bool {
+	for _, word := range c.words {
+		if bytes.Contains(r.Body, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// payloadCriterion 判断 payload 是否为列表中的值
+type payloadCriterion struct {
+	payloads map[string]bool
+}
+
+func (c payloadCriterion) Match(r *Response) bool {
+	return c.payloads[r.Payload]
+}
+
+// headerCriterion 判断响应头，支持以下形式：
+//
+//	Set-Cookie                   存在该响应头
+//	X-Cache=HIT                  某个值等于 HIT
+//	X-Cache!=HIT                 没有值等于 HIT
+//	Content-Type: text/html      某个值包含 text/html（忽略大小写）
+//	Server~(?i)^apache           某个值匹配正则表达式
+//	Server!~(?i)^apache          没有值匹配正则表达式
+type headerCriterion struct {
+	name   string
+	negate bool
+	match  func(value string) bool // 为 nil 时只判断是否存在
+}
+
+func (c headerCriterion) Match(r *Response) bool {
+	values := r.Header.Values(c.name)
+	if c.match == nil {
+		return len(values) > 0
+	}
+	for _, v := range values {
+		if c.match(v) {
+			return !c.negate
+		}
+	}
+	return c.negate
+}
+
+// 解析响应头条件
+func parseHeaderCriterion(s string) (Criterion, error) {
+	i := strings.IndexAny(s, "=:~!")
+	if i < 0 {
+		name := strings.TrimSpace(s)
+		if name == "" {
+			return nil, errors.New("empty header name")
+		}
+		return headerCriterion{name: name}, nil
+	}
+
+	c := headerCriterion{name: strings.TrimSpace(s[:i])}
+	if c.name == "" {
+		return nil, errors.New("empty header name")
+	}
+	op, value := s[i:i+1], s[i+1:]
+	if op == "!" {
+		if value == "" || (value[0] != '=' && value[0] != '~') {
+			return nil, fmt.Errorf("invalid header operator in %q", s)
+		}
+		c.negate = true
+		op, value = value[:1], value[1:]
+	}
+
+	switch op {
+	case "=":
+		c.match = func(v string) bool {
+			return v == value
+		}
+	case ":":
+		value = strings.ToLower(strings.TrimSpace(value))
+		c.match = func(v string) bool {
+			return strings.Contains(strings.ToLower(v), value)
+		}
+	case "~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		c.match = re.MatchString
+	}
+	return c, nil
+}
+
+// durationCriterion 判断响应时间，支持 >2s、>=2s、<100ms、<=100ms 和
+// 100ms-2s 这样的范围
+type durationCriterion struct {
+	min, max time.Duration
+	bounded  bool // 是否有上限 max
+	value    func(r *Response) time.Duration
+}
+
+func (c durationCriterion) Match(r *Response) bool {
+	d := c.value(r)
+	return d >= c.min && (!c.bounded || d <= c.max)
+}
+
+// newDurationCriterion 返回按响应时间解析条件的函数
+func newDurationCriterion(value func(r *Response) time.Duration) func(string) (Criterion, error) {
+	return func(s string) (Criterion, error) {
+		s = strings.ReplaceAll(s, " ", "") // 去除空格
+		c := durationCriterion{value: value}
+
+		var err error
+		switch {
+		case strings.HasPrefix(s, ">="):
+			c.min, err = time.ParseDuration(s[2:])
+		case strings.HasPrefix(s, ">"):
+			c.min, err = time.ParseDuration(s[1:])
+			c.min++
+		case strings.HasPrefix(s, "<="):
+			c.max, err = time.ParseDuration(s[2:])
+			c.bounded = true
+		case strings.HasPrefix(s, "<"):
+			c.max, err = time.ParseDuration(s[1:])
+			c.max--
+			c.bounded = true
+		default:
+			start, end, ok := strings.Cut(s, "-")
+			if !ok {
+				return nil, fmt.Errorf("invalid duration condition %q", s)
+			}
+			if c.min, err = time.ParseDuration(start); err == nil {
+				c.max, err = time.ParseDuration(end)
+			}
+			c.bounded = true
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c.bounded && c.max < c.min {
+			return nil, fmt.Errorf("invalid duration condition %q", s)
+		}
+		return c, nil
+	}
+}
+
+func init() {
+	RegisterCriterion("status", newRangeCriterion(func(r *Response) int64 {
+		return int64(r.StatusCode)
+	}))
+	RegisterCriterion("size", newRangeCriterion(func(r *Response) int64 {
+		return r.Size
+	}))
+	RegisterCriterion("words", newRangeCriterion(func(r *Response) int64 {
+		return int64(r.Words)
+	}))
+	RegisterCriterion("lines", newRangeCriterion(func(r *Response) int64 {
+		return int64(r.Lines)
+	}))
+	RegisterCriterion("regex", func(s string) (Criterion, error) {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+		return regexCriterion{re}, nil
+	})
+	RegisterCriterion("header", parseHeaderCriterion)
+	RegisterCriterion("duration", newDurationCriterion(func(r *Response) time.Duration {
+		return r.Duration
+	}))
+	RegisterCriterion("ttfb", newDurationCriterion(func(r *Response) time.Duration {
+		return r.TTFB
+	}))
+	RegisterCriterion("string", func(s string) (Criterion, error) {
+		var words [][]byte
+		for _, word := range splitString(s) {
+			words = append(words, []byte(word))
+		}
+		if len(words) == 0 {
+			return nil, errors.New("empty list")
+		}
+		return stringCriterion{words}, nil
+	})
+	RegisterCriterion("payload", func(s string) (Criterion, error) {
+		payloads := make(map[string]bool)
+		for _, p := range splitString(s) {
+			payloads[p] = true
+		}
+		if len(payloads) == 0 {
+			return nil, errors.New("empty list")
+		}
+		return payloadCriterion{payloads}, nil
+	})
+}
+
+// CountWords 返回响应体中以空白分隔的单词数
+func CountWords(body []byte) int {
+	return len(bytes.Fields(body))
+}
+
+// CountLines 返回响应体的行数
+func CountLines(body []byte) int {
+	if len(body) == 0 {
+		return 0
+	}
+	return bytes.Count(body, []byte("\n")) + 1
+}
+
+// 将字符串按照逗号分割成切片
+func splitString(s string) []string {
+	var ret []string
+	for _, v := range strings.Split(s, ",") {
+		if v != "" {
+			ret = append(ret, v)
+		}
+	}
+	return ret
 }
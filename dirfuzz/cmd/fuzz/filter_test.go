@@ -0,0 +1,272 @@
+package fuzz
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	ok := &Response{StatusCode: 200, Size: 10, Body: []byte("welcome admin")}
+	notFound := &Response{StatusCode: 404, Size: 0, Body: []byte("Not Found")}
+	tests := []struct {
+		rule         string
+		ok, notFound bool
+	}{
+		{"status:200", true, false},
+		{"status:200-299,404", true, true},
+		{"!status:404", true, false},
+		{"!!status:404", false, true},
+		{"! status:404", true, false},
+		{"status:200 && size:0", false, false},
+		{"status:200 && regex:admin", true, false},
+		{"status:404 || size:10", true, true},
+		// && binds tighter than ||.
+		{"status:404 && size:10 || regex:admin", true, false},
+		{"status:200-299 && !regex:(?i)not found || size:0", true, true},
+		{"  status:200  ", true, false},
+		{"STATUS:200", true, false},
+		{"string:foo,admin", true, false},
+		{"payload:x", false, false},
+		// Operators inside a value are left to the criterion.
+		{"regex:^(welcome|Not)", true, true},
+		{"regex:^(Not||x)$ || size:10", true, false},
+		{"regex:admin&&x || status:404", false, true},
+		{"regex:^(welcome||x) && size:10", true, false},
+	}
+	for _, tt := range tests {
+		c, err := ParseRule(tt.rule)
+		if err != nil {
+			t.Errorf("ParseRule(%q): %v", tt.rule, err)
+			continue
+		}
+		if got := c.Match(ok); got != tt.ok {
+			t.Errorf("ParseRule(%q) matches 200 = %v, want %v", tt.rule, got, tt.ok)
+		}
+		if got := c.Match(notFound); got != tt.notFound {
+			t.Errorf("ParseRule(%q) matches 404 = %v, want %v", tt.rule, got, tt.notFound)
+		}
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"status",
+		"nope:1",
+		"status:abc",
+		"status:300-200",
+		"status:200 &&",
+		"regex:(",
+		"string:,",
+	} {
+		if _, err := ParseRule(rule); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("ParseRule(%q) error = %v, want ErrInvalidFilter", rule, err)
+		}
+	}
+}
+
+func TestFilterResponse(t *testing.T) {
+	r := &Response{StatusCode: 200, Size: 42}
+	tests := []struct {
+		name              string
+		matchers, filters []string
+		matchMode         string
+		filterMode        string
+		want              bool
+	}{
+		{"no rules", nil, nil, "", "", true},
+		{"matcher holds", []string{"status:200"}, nil, "", "", true},
+		{"matcher fails", []string{"status:404"}, nil, "", "", false},
+		{"any matcher", []string{"status:404", "size:42"}, nil, ModeOr, "", true},
+		{"all matchers", []string{"status:404", "size:42"}, nil, ModeAnd, "", false},
+		{"filter holds", nil, []string{"size:42"}, "", "", false},
+		{"any filter", nil, []string{"size:1", "status:200"}, "", ModeOr, false},
+		{"all filters", nil, []string{"size:1", "status:200"}, "", ModeAnd, true},
+		{"matched then filtered", []string{"status:200"}, []string{"size:42"}, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := (&Options{Matchers: tt.matchers, Filters: tt.filters, MatchMode: tt.matchMode, FilterMode: tt.filterMode}).newFilter()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.matchers == nil {
+				// Without matchers, newFilter keeps everything but 404.
+				f.Matchers = nil
+			}
+			if got := f.FilterResponse(r); got != tt.want {
+				t.Errorf("FilterResponse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFilterDefaultMatcher(t *testing.T) {
+	f, err := (&Options{}).newFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.FilterResponse(&Response{StatusCode: 404}) {
+		t.Error("404 kept without matchers")
+	}
+	if !f.FilterResponse(&Response{StatusCode: 500}) {
+		t.Error("500 dropped without matchers")
+	}
+}
+
+func TestIgnoreRegexIsNotARule(t *testing.T) {
+	// ||, && and ! belong to the regular expression.
+	f, err := (&Options{IgnoreRegex: `^(a||b)&&!$`}).newFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.FilterResponse(&Response{StatusCode: 200, Body: []byte("b&&!")}) {
+		t.Error("response matching the ignore regex kept")
+	}
+	if !f.FilterResponse(&Response{StatusCode: 200, Body: []byte("b")}) {
+		t.Error("response not matching the ignore regex dropped")
+	}
+
+	if _, err := (&Options{IgnoreRegex: "("}).newFilter(); err == nil {
+		t.Error("invalid ignore regex accepted")
+	}
+}
+
+func TestFilterPayloads(t *testing.T) {
+	f := NewFilter()
+	f.Blacklist("a")
+	if f.Match("a") || !f.Match("b") {
+		t.Error("blacklist not applied")
+	}
+	f.Whitelist("c")
+	if f.Match("b") || !f.Match("c") {
+		t.Error("whitelist not applied")
+	}
+}
+
+func TestCountWordsAndLines(t *testing.T) {
+	tests := []struct {
+		body         string
+		words, lines int
+	}{
+		{"", 0, 0},
+		{"one", 1, 1},
+		{"one two\nthree", 3, 2},
+		{"  spaced \t out \r\n\n", 2, 3},
+		{"<p>a</p>\n", 1, 2},
+	}
+	for _, tt := range tests {
+		if got := CountWords([]byte(tt.body)); got != tt.words {
+			t.Errorf("CountWords(%q) = %d, want %d", tt.body, got, tt.words)
+		}
+		if got := CountLines([]byte(tt.body)); got != tt.lines {
+			t.Errorf("CountLines(%q) = %d, want %d", tt.body, got, tt.lines)
+		}
+	}
+}
+
+func TestWordAndLineCriteria(t *testing.T) {
+	r := &Response{StatusCode: 200, Words: 12, Lines: 3}
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{"words:12", true},
+		{"words:0-11", false},
+		{"words:10-20 && lines:3", true},
+		{"!lines:1-2", true},
+		{"lines:4,5", false},
+	}
+	for _, tt := range tests {
+		c, err := ParseRule(tt.rule)
+		if err != nil {
+			t.Errorf("ParseRule(%q): %v", tt.rule, err)
+			continue
+		}
+		if got := c.Match(r); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestHeaderCriterion(t *testing.T) {
+	r := &Response{StatusCode: 200, Header: http.Header{
+		"Set-Cookie":   {"a=1", "b=2"},
+		"X-Cache":      {"HIT"},
+		"Content-Type": {"Text/HTML; charset=utf-8"},
+		"Server":       {"Apache/2.4"},
+	}}
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{"header:Set-Cookie", true},
+		{"header:set-cookie", true},
+		{"header:X-Powered-By", false},
+		{"!header:X-Powered-By", true},
+		{"header:X-Cache=HIT", true},
+		{"header:X-Cache=hit", false},
+		{"header:X-Cache!=HIT", false},
+		{"header:X-Cache!=MISS", true},
+		{"header:Set-Cookie=b=2", true},
+		{"header:Content-Type: text/html", true},
+		{"header:Content-Type:json", false},
+		{"header:Server~(?i)^apache", true},
+		{"header:Server!~(?i)^apache", false},
+		{"header:Server~^nginx", false},
+		{"header:X-Powered-By!~.", true},
+		{"header:Server && status:200", true},
+	}
+	for _, tt := range tests {
+		c, err := ParseRule(tt.rule)
+		if err != nil {
+			t.Errorf("ParseRule(%q): %v", tt.rule, err)
+			continue
+		}
+		if got := c.Match(r); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+
+	for _, rule := range []string{"header:", "header:=x", "header:Server!x", "header:Server~("} {
+		if _, err := ParseRule(rule); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("ParseRule(%q) error = %v", rule, err)
+		}
+	}
+}
+
+func TestDurationCriterion(t *testing.T) {
+	r := &Response{StatusCode: 200, TTFB: 80 * time.Millisecond, Duration: 2 * time.Second}
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{"duration:>1s", true},
+		{"duration:>2s", false},
+		{"duration:>=2s", true},
+		{"duration:<2s", false},
+		{"duration:<=2s", true},
+		{"duration:1s-3s", true},
+		{"duration:100ms - 1.5s", false},
+		{"ttfb:<100ms", true},
+		{"ttfb:>100ms || duration:>1s", true},
+	}
+	for _, tt := range tests {
+		c, err := ParseRule(tt.rule)
+		if err != nil {
+			t.Errorf("ParseRule(%q): %v", tt.rule, err)
+			continue
+		}
+		if got := c.Match(r); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+
+	for _, rule := range []string{"duration:2s", "duration:>", "duration:<x", "duration:3s-1s", "ttfb:1-2"} {
+		if _, err := ParseRule(rule); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("ParseRule(%q) error = %v", rule, err)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package fuzz
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/big"
+	mathrand "math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generators produce payloads in place of a wordlist file:
+//
+//	range:0001-9999        numbers, zero-padded to the width of the start
+//	range:0-1000:10        numbers with a step
+//	range:0x00-0xff        hexadecimal numbers
+//	charset:a-z0-9:1-3     every string of 1 to 3 characters of the set
+//	date:2020-01-01..2026-12-31:20060102
+//	                       every day of the range in a Go time layout
+//	uuid:1000              random version 4 UUIDs
+//	uuid:START:1000        sequential UUIDs from START
+//	hex:32:1000            random hex tokens of 32 characters
+//
+// Random generators yield the same payloads every time they are read during
+// a run, so that every directory gets the same tokens.
+var generatorParsers = map[string]func(args string) (*generator, error){
+	"range":   parseRangeGenerator,
+	"charset": parseCharsetGenerator,
+	"date":    parseDateGenerator,
+	"uuid":    parseUUIDGenerator,
+	"hex":     parseHexGenerator,
+}
+
+// maxCharsetPayloads bounds the payloads of a charset generator.
+const maxCharsetPayloads = 1 << 40
+
+// generatorSalt makes the payloads of random generators differ between runs.
+var generatorSalt = func() int64 {
+	var b [8]byte
+	rand.Read(b[:])
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}()
+
+// generator produces payloads without a file.
+type generator struct {
+	count int64
+	// start returns a function yielding the payloads in order, and false
+	// after the last one.
+	start func() func() (string, bool)
+}
+
+// isGenerator reports whether a wordlist path names a generator.
+func isGenerator(spec string) bool {
+	name, _, ok := strings.Cut(spec, ":")
+	_, known := generatorParsers[name]
+	return ok && known
+}
+
+// parseGenerator parses a generator such as "range:1-100".
+func parseGenerator(spec string) (*generator, error) {
+	name, args, _ := strings.Cut(spec, ":")
+	parse, ok := generatorParsers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown generator %q", name)
+	}
+	g, err := parse(args)
+	if err != nil {
+		return nil, fmt.Errorf("invalid generator %q: %v", spec, err)
+	}
+	return g, nil
+}
+
+// openGenerator returns the payloads of a generator, one per line.
+func openGenerator(spec string) (io.ReadCloser, error) {
+	g, err := parseGenerator(spec)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(&generatorReader{next: g.start()}), nil
+}
+
+// generatorReader reads the payloads of a generator as lines.
+type generatorReader struct {
+	next func() (string, bool)
+	buf  []byte
+	done bool
+}
+
+func (r *generatorReader) Read(p []byte) (int, error) {
+	for len(r.buf) < len(p) && !r.done {
+		word, ok := r.next()
+		if !ok {
+			r.done = true
+			break
+		}
+		r.buf = append(r.buf, word...)
+		r.buf = append(r.buf, '\n')
+	}
+	if len(r.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[:copy(r.buf, r.buf[n:])]
+	return n, nil
+}
+
+// parseRangeGenerator parses "START-END[:STEP]".
+func parseRangeGenerator(args string) (*generator, error) {
+	bounds, stepArg, hasStep := strings.Cut(args, ":")
+	lo, hi, ok := strings.Cut(bounds, "-")
+	if !ok {
+		return nil, errors.New("want START-END[:STEP]")
+	}
+
+	base := 10
+	if strings.HasPrefix(lo, "0x") && strings.HasPrefix(hi, "0x") {
+		base = 16
+		lo, hi = lo[2:], hi[2:]
+	}
+	from, err := strconv.ParseInt(lo, base, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start %q", lo)
+	}
+	to, err := strconv.ParseInt(hi, base, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end %q", hi)
+	}
+	if from > to {
+		return nil, errors.New("start is greater than end")
+	}
+	step := int64(1)
+	if hasStep {
+		if step, err = strconv.ParseInt(stepArg, 10, 64); err != nil || step < 1 {
+			return nil, fmt.Errorf("invalid step %q", stepArg)
+		}
+	}
+
+	// Zero padding is taken from the start, as in 0001 or 0x00.
+	width := 0
+	if len(lo) > 1 && lo[0] == '0' || base == 16 {
+		width = len(lo)
+	}
+	prefix := ""
+	if base == 16 {
+		prefix = "0x"
+	}
+
+	return &generator{
+		count: (to-from)/step + 1,
+		start: func() func() (string, bool) {
+			n := from
+			return func() (string, bool) {
+				if n > to || n < from {
+					return "", false
+				}
+				s := strconv.FormatInt(n, base)
+				if pad := width - len(s); pad > 0 {
+					s = strings.Repeat("0", pad) + s
+				}
+				n += step
+				return prefix + s, true
+			}
+		},
+	}, nil
+}
+
+// parseCharsetGenerator parses "SET:LEN" or "SET:MIN-MAX", where SET may
+// contain ranges such as a-z.
+func parseCharsetGenerator(args string) (*generator, error) {
+	i := strings.LastIndex(args, ":")
+	if i < 0 {
+		return nil, errors.New("want SET:MIN-MAX")
+	}
+	set, err := expandCharset(args[:i])
+	if err != nil {
+		return nil, err
+	}
+	lo, hi, ok := strings.Cut(args[i+1:], "-")
+	if !ok {
+		hi = lo
+	}
+	min, err1 := strconv.Atoi(lo)
+	max, err2 := strconv.Atoi(hi)
+	if err1 != nil || err2 != nil || min < 1 || min > max {
+		return nil, fmt.Errorf("invalid length %q", args[i+1:])
+	}
+
+	var count int64
+	for n := min; n <= max; n++ {
+		c := new(big.Int).Exp(big.NewInt(int64(len(set))), big.NewInt(int64(n)), nil)
+		if !c.IsInt64() || count+c.Int64() > maxCharsetPayloads {
+			return nil, fmt.Errorf("more than %d payloads", int64(maxCharsetPayloads))
+		}
+		count += c.Int64()
+	}
+
+	return &generator{
+		count: count,
+		start: func() func() (string, bool) {
+			// indexes is an odometer over the set.
+			indexes := make([]int, min)
+			done := false
+			return func() (string, bool) {
+				if done {
+					return "", false
+				}
+				word := make([]rune, len(indexes))
+				for j, k := range indexes {
+					word[j] = set[k]
+				}
+
+				// Advance the odometer, growing it when it wraps.
+				j := len(indexes) - 1
+				for ; j >= 0; j-- {
+					indexes[j]++
+					if indexes[j] < len(set) {
+						break
+					}
+					indexes[j] = 0
+				}
+				if j < 0 {
+					if len(indexes) == max {
+						done = true
+					} else {
+						indexes = make([]int, len(indexes)+1)
+					}
+				}
+				return string(word), true
+			}
+		},
+	}, nil
+}
+
+// expandCharset expands ranges such as a-z in a character set. A dash at
+// the start or end is taken literally.
+func expandCharset(s string) ([]rune, error) {
+	in := []rune(s)
+	var set []rune
+	seen := make(map[rune]bool)
+	add := func(c rune) {
+		if !seen[c] {
+			seen[c] = true
+			set = append(set, c)
+		}
+	}
+	for i := 0; i < len(in); i++ {
+		if i+2 < len(in) && in[i+1] == '-' {
+			if in[i] > in[i+2] {
+				return nil, fmt.Errorf("invalid range %c-%c", in[i], in[i+2])
+			}
+			for c := in[i]; c <= in[i+2]; c++ {
+				add(c)
+			}
+			i += 2
+			continue
+		}
+		add(in[i])
+	}
+	if len(set) == 0 {
+		return nil, errors.New("empty character set")
+	}
+	return set, nil
+}
+
+// parseDateGenerator parses "FROM..TO:LAYOUT" with dates as YYYY-MM-DD and
+// a Go time layout.
+func parseDateGenerator(args string) (*generator, error) {
+	bounds, layout, ok := strings.Cut(args, ":")
+	if !ok || layout == "" {
+		return nil, errors.New("want FROM..TO:LAYOUT")
+	}
+	lo, hi, ok := strings.Cut(bounds, "..")
+	if !ok {
+		return nil, errors.New("want FROM..TO:LAYOUT")
+	}
+	from, err := time.Parse("2006-01-02", lo)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start %q", lo)
+	}
+	to, err := time.Parse("2006-01-02", hi)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end %q", hi)
+	}
+	if from.After(to) {
+		return nil, errors.New("start is after end")
+	}
+
+	return &generator{
+		count: int64(to.Sub(from).Hours()/24) + 1,
+		start: func() func() (string, bool) {
+			day := from
+			return func() (string, bool) {
+				if day.After(to) {
+					return "", false
+				}
+				s := day.Format(layout)
+				day = day.AddDate(0, 0, 1)
+				return s, true
+			}
+		},
+	}, nil
+}
+
+// parseUUIDGenerator parses "N" for random UUIDs or "START:N" for
+// sequential ones.
+func parseUUIDGenerator(args string) (*generator, error) {
+	first, countArg, sequential := strings.Cut(args, ":")
+	if !sequential {
+		countArg = first
+	}
+	count, err := strconv.ParseInt(countArg, 10, 64)
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("invalid count %q", countArg)
+	}
+
+	if !sequential {
+		seed := generatorSeed("uuid:" + args)
+		return &generator{
+			count: count,
+			start: func() func() (string, bool) {
+				r := mathrand.New(mathrand.NewSource(seed))
+				n := int64(0)
+				return func() (string, bool) {
+					if n >= count {
+						return "", false
+					}
+					n++
+					var u [16]byte
+					r.Read(u[:])
+					u[6] = u[6]&0x0f | 0x40 // version 4
+					u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+					return formatUUID(u[:]), true
+				}
+			},
+		}, nil
+	}
+
+	b, err := hex.DecodeString(strings.ReplaceAll(first, "-", ""))
+	if err != nil || len(b) != 16 {
+		return nil, fmt.Errorf("invalid UUID %q", first)
+	}
+	return &generator{
+		count: count,
+		start: func() func() (string, bool) {
+			u := new(big.Int).SetBytes(b)
+			one := big.NewInt(1)
+			n := int64(0)
+			return func() (string, bool) {
+				if n >= count {
+					return "", false
+				}
+				n++
+				var buf [16]byte
+				u.FillBytes(buf[:])
+				u.Add(u, one)
+				if u.BitLen() > 128 {
+					u.SetInt64(0)
+				}
+				return formatUUID(buf[:]), true
+			}
+		},
+	}, nil
+}
+
+// parseHexGenerator parses "LEN:N" for N random hex tokens of LEN
+// characters.
+func parseHexGenerator(args string) (*generator, error) {
+	lenArg, countArg, ok := strings.Cut(args, ":")
+	if !ok {
+		return nil, errors.New("want LEN:N")
+	}
+	length, err := strconv.Atoi(lenArg)
+	if err != nil || length < 1 {
+		return nil, fmt.Errorf("invalid length %q", lenArg)
+	}
+	count, err := strconv.ParseInt(countArg, 10, 64)
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("invalid count %q", countArg)
+	}
+
+	seed := generatorSeed("hex:" + args)
+	return &generator{
+		count: count,
+		start: func() func() (string, bool) {
+			r := mathrand.New(mathrand.NewSource(seed))
+			b := make([]byte, (length+1)/2)
+			n := int64(0)
+			return func() (string, bool) {
+				if n >= count {
+					return "", false
+				}
+				n++
+				r.Read(b)
+				return hex.EncodeToString(b)[:length], true
+			}
+		},
+	}, nil
+}
+
+// generatorSeed returns the seed of a random generator, which is the same
+// for every read during a run.
+func generatorSeed(spec string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(spec))
+	return int64(h.Sum64()) ^ generatorSalt
+}
+
+// formatUUID formats 16 bytes as a UUID.
+func formatUUID(b []byte) string {
+	s := hex.EncodeToString(b)
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
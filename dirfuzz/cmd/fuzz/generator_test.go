@@ -0,0 +1,145 @@
+package fuzz
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// generated returns the payloads of a generator, checking its count.
+func generated(t *testing.T, spec string) []string {
+	t.Helper()
+	g, err := parseGenerator(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := openGenerator(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if int64(len(payloads)) != g.count {
+		t.Errorf("%s made %d payloads, counted %d", spec, len(payloads), g.count)
+	}
+	return payloads
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		spec, want string
+	}{
+		{"range:1-3", "1 2 3"},
+		{"range:0008-0011", "0008 0009 0010 0011"},
+		{"range:1-10:3", "1 4 7 10"},
+		{"range:0-9:5", "0 5"},
+		{"range:0x0e-0x11", "0x0e 0x0f 0x10 0x11"},
+		{"charset:ab:1-2", "a b aa ab ba bb"},
+		{"charset:a-c:1", "a b c"},
+		{"charset:-x-:1", "- x"},
+		{"date:2024-02-27..2024-03-01:20060102", "20240227 20240228 20240229 20240301"},
+		{"date:2024-12-31..2025-01-01:2006/01/02", "2024/12/31 2025/01/01"},
+		{"uuid:00000000-0000-0000-0000-0000000000fe:3",
+			"00000000-0000-0000-0000-0000000000fe 00000000-0000-0000-0000-0000000000ff 00000000-0000-0000-0000-000000000100"},
+		{"uuid:ffffffffffffffffffffffffffffffff:2",
+			"ffffffff-ffff-ffff-ffff-ffffffffffff 00000000-0000-0000-0000-000000000000"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(generated(t, tt.spec), " "); got != tt.want {
+			t.Errorf("%s made %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestRandomGenerators(t *testing.T) {
+	uuids := generated(t, "uuid:50")
+	seen := make(map[string]bool)
+	for _, u := range uuids {
+		if len(u) != 36 || u[14] != '4' || !strings.ContainsRune("89ab", rune(u[19])) {
+			t.Errorf("%s is not a version 4 UUID", u)
+		}
+		seen[u] = true
+	}
+	if len(seen) != len(uuids) {
+		t.Errorf("made %d distinct UUIDs of %d", len(seen), len(uuids))
+	}
+
+	tokens := generated(t, "hex:7:3")
+	for _, token := range tokens {
+		if len(token) != 7 || strings.Trim(token, "0123456789abcdef") != "" {
+			t.Errorf("%q is not a hex token of 7 characters", token)
+		}
+	}
+
+	// Every read of a random generator yields the same payloads.
+	for _, spec := range []string{"uuid:50", "hex:7:3"} {
+		first, second := generated(t, spec), generated(t, spec)
+		if strings.Join(first, " ") != strings.Join(second, " ") {
+			t.Errorf("%s differs between reads", spec)
+		}
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	for _, spec := range []string{
+		"nope:1",
+		"range:1",
+		"range:5-1",
+		"range:1-5:0",
+		"range:a-z",
+		"charset:ab",
+		"charset:z-a:1",
+		"charset:ab:0",
+		"charset:ab:3-2",
+		"charset:a-z:1-20",
+		"date:2024-01-01..2024-01-31",
+		"date:2024-01-02..2024-01-01:20060102",
+		"date:2024-13-01..2024-12-31:20060102",
+		"uuid:0",
+		"uuid:not-a-uuid:3",
+		"hex:32",
+		"hex:0:1",
+		"hex:8:-1",
+	} {
+		if _, err := parseGenerator(spec); err == nil {
+			t.Errorf("parseGenerator(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestIsGenerator(t *testing.T) {
+	tests := []struct {
+		spec string
+		want bool
+	}{
+		{"range:1-3", true},
+		{"uuid:", true},
+		{"range", false},
+		{"words.txt", false},
+		{"lists.zip:*.txt", false},
+	}
+	for _, tt := range tests {
+		if got := isGenerator(tt.spec); got != tt.want {
+			t.Errorf("isGenerator(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestScanGenerator(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/user/0042" {
+			w.Write([]byte("ok"))
+			return
+		}
+		http.NotFound(w, r)
+	}
+	got := scan(t, handler, Options{TargetURL: "SERVER/user/FUZZ", WordlistFile: "range:0001-0100", NoRecursion: true})
+	if strings.Join(got, " ") != "/user/0042" {
+		t.Errorf("found %v", got)
+	}
+}
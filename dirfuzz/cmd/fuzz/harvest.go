@@ -0,0 +1,260 @@
+package fuzz
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Bounds of the length of harvested tokens.
+const (
+	defaultMinTokenLength = 3
+	maxTokenLength        = 40
+)
+
+var (
+	// commentPattern matches HTML comments.
+	commentPattern = regexp.MustCompile(`(?s)<!--(.*?)-->`)
+	// identifierPattern matches JavaScript identifiers.
+	identifierPattern = regexp.MustCompile(`[A-Za-z_$][A-Za-z0-9_$]*`)
+	// jsPathPattern matches quoted paths in JavaScript, such as "/api/users".
+	jsPathPattern = regexp.MustCompile(`["'\x60](/[A-Za-z0-9_\-./]+)["'\x60]`)
+)
+
+// jsKeywords are JavaScript keywords and globals that are not worth trying
+// as paths.
+var jsKeywords = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true, "const": true,
+	"continue": true, "debugger": true, "default": true, "delete": true,
+	"else": true, "export": true, "extends": true, "false": true,
+	"finally": true, "for": true, "function": true, "if": true,
+	"import": true, "instanceof": true, "let": true, "new": true,
+	"null": true, "return": true, "super": true, "switch": true,
+	"this": true, "throw": true, "true": true, "try": true, "typeof": true,
+	"undefined": true, "var": true, "void": true, "while": true,
+	"with": true, "yield": true, "async": true, "await": true,
+	"document": true, "window": true, "console": true, "prototype": true,
+	"length": true,
+}
+
+// Harvester collects tokens from response bodies, CeWL-style: words of the
+// visible text, ids, class names, form field names, path segments of links
+// to the same host, words in comments and JavaScript identifiers and paths.
+// It is safe for concurrent use.
+type Harvester struct {
+	minLength int
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// HarvestedWord is a harvested token with the number of times it was seen.
+type HarvestedWord struct {
+	Word  string
+	Count int
+}
+
+// NewHarvester returns a harvester that keeps tokens of at least minLength
+// characters, or 3 if minLength is not positive.
+func NewHarvester(minLength int) *Harvester {
+	if minLength < 1 {
+		minLength = defaultMinTokenLength
+	}
+	return &Harvester{minLength: minLength, counts: make(map[string]int)}
+}
+
+// Harvest collects the tokens of a response body. JavaScript responses are
+// scanned for identifiers and paths, HTML responses are parsed.
+func (h *Harvester) Harvest(pageURL *url.URL, header http.Header, body []byte) {
+	contentType := header.Get("Content-Type")
+	var tokens []string
+	switch {
+	case strings.Contains(contentType, "javascript"):
+		tokens = h.scriptTokens(pageURL, string(body))
+	case strings.Contains(contentType, "html"), contentType == "" && looksLikeHTML(body):
+		tokens = h.htmlTokens(pageURL, body)
+	default:
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, token := range tokens {
+		h.counts[token]++
+	}
+}
+
+// htmlTokens returns the tokens of an HTML page.
+func (h *Harvester) htmlTokens(pageURL *url.URL, body []byte) []string {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var tokens []string
+	add := func(s string) {
+		if h.keep(s) {
+			tokens = append(tokens, s)
+		}
+	}
+
+	doc.Find("[id]").Each(func(_ int, sel *goquery.Selection) {
+		add(sel.AttrOr("id", ""))
+	})
+	doc.Find("[class]").Each(func(_ int, sel *goquery.Selection) {
+		for _, class := range strings.Fields(sel.AttrOr("class", "")) {
+			add(class)
+		}
+	})
+	doc.Find("input[name], select[name], textarea[name], button[name], form[name]").Each(func(_ int, sel *goquery.Selection) {
+		add(sel.AttrOr("name", ""))
+	})
+	for _, attr := range []string{"href", "src", "action"} {
+		doc.Find("[" + attr + "]").Each(func(_ int, sel *goquery.Selection) {
+			for _, segment := range linkSegments(pageURL, sel.AttrOr(attr, "")) {
+				add(segment)
+			}
+		})
+	}
+	doc.Find("script").Each(func(_ int, sel *goquery.Selection) {
+		tokens = append(tokens, h.scriptTokens(pageURL, sel.Text())...)
+	})
+	for _, m := range commentPattern.FindAllSubmatch(body, -1) {
+		tokens = append(tokens, h.words(string(m[1]))...)
+	}
+
+	// Visible text only
+	doc.Find("script, style").Remove()
+	tokens = append(tokens, h.words(doc.Text())...)
+	return tokens
+}
+
+// scriptTokens returns the identifiers and the segments of quoted paths in
+// JavaScript code.
+func (h *Harvester) scriptTokens(pageURL *url.URL, code string) []string {
+	var tokens []string
+	for _, m := range jsPathPattern.FindAllStringSubmatch(code, -1) {
+		for _, segment := range linkSegments(pageURL, m[1]) {
+			if h.keep(segment) {
+				tokens = append(tokens, segment)
+			}
+		}
+	}
+	for _, id := range identifierPattern.FindAllString(code, -1) {
+		if !jsKeywords[id] && h.keep(id) {
+			tokens = append(tokens, id)
+		}
+	}
+	return tokens
+}
+
+// words splits text into words of letters, digits, dashes and underscores.
+func (h *Harvester) words(text string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_'
+	}) {
+		word = strings.Trim(word, "-_")
+		if h.keep(word) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// keep reports whether a token is worth trying as a path segment.
+func (h *Harvester) keep(token string) bool {
+	n := len([]rune(token))
+	return n >= h.minLength && n <= maxTokenLength && !strings.ContainsAny(token, " \t\r\n/?#")
+}
+
+// linkSegments returns the path segments of a link to the page's host,
+// and for file names also the name without its extension.
+func linkSegments(pageURL *url.URL, link string) []string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return nil
+	}
+	if pageURL != nil {
+		u = pageURL.ResolveReference(u)
+		if u.Host != pageURL.Host {
+			return nil
+		}
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		segments = append(segments, segment)
+		if ext := path.Ext(segment); ext != "" && ext != segment {
+			segments = append(segments, strings.TrimSuffix(segment, ext))
+		}
+	}
+	return segments
+}
+
+// looksLikeHTML reports whether an untyped body starts like an HTML page.
+func looksLikeHTML(body []byte) bool {
+	start := bytes.ToLower(bytes.TrimSpace(body))
+	if len(start) > 512 {
+		start = start[:512]
+	}
+	return bytes.HasPrefix(start, []byte("<!doctype html")) || bytes.Contains(start, []byte("<html"))
+}
+
+// Words returns the harvested tokens, most frequent first.
+func (h *Harvester) Words() []HarvestedWord {
+	h.mu.Lock()
+	words := make([]HarvestedWord, 0, len(h.counts))
+	for word, count := range h.counts {
+		words = append(words, HarvestedWord{Word: word, Count: count})
+	}
+	h.mu.Unlock()
+
+	sort.Slice(words, func(i, j int) bool {
+		if words[i].Count != words[j].Count {
+			return words[i].Count > words[j].Count
+		}
+		return words[i].Word < words[j].Word
+	})
+	return words
+}
+
+// Len returns the number of distinct harvested tokens.
+func (h *Harvester) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.counts)
+}
+
+// Save writes the harvested tokens to a file, one per line and most
+// frequent first, so that it can be used as a wordlist.
+func (h *Harvester) Save(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	for _, word := range h.Words() {
+		w.WriteString(word.Word)
+		w.WriteString("\n")
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
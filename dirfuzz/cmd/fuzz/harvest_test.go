@@ -0,0 +1,135 @@
+package fuzz
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// harvested returns the harvested words, sorted.
+func harvested(h *Harvester) []string {
+	var words []string
+	for _, w := range h.Words() {
+		words = append(words, w.Word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+func TestHarvestHTML(t *testing.T) {
+	page, _ := url.Parse("http://example.org/shop/index.php")
+	body := `<!DOCTYPE html>
+<html><head><title>Acme Store</title><style>.hidden{color:red}</style></head>
+<body>
+<div id="main-content" class="wrapper dark">Welcome to our store</div>
+<form name="login" action="/account/signin.aspx">
+  <input name="username"><input name="pw">
+</form>
+<a href="../downloads/catalog.pdf">x</a>
+<a href="https://cdn.example.com/assets/vendor.js">cdn</a>
+<img src="images/logo.png">
+<!-- TODO remove staging -->
+<script>var apiBase = "/api/internal/users"; function loadCart() {}</script>
+</body></html>`
+	h := NewHarvester(0)
+	h.Harvest(page, http.Header{"Content-Type": {"text/html; charset=utf-8"}}, []byte(body))
+	got := harvested(h)
+
+	for _, want := range []string{
+		"Acme", "Store", "Welcome", "store", // visible text
+		"main-content", "wrapper", "dark", // ids and classes
+		"login", "username", // form names
+		"account", "signin.aspx", "signin", // same-host links
+		"downloads", "catalog.pdf", "catalog", "shop", "images", "logo",
+		"TODO", "remove", "staging", // comments
+		"apiBase", "loadCart", "api", "internal", "users", // scripts
+	} {
+		if i := sort.SearchStrings(got, want); i == len(got) || got[i] != want {
+			t.Errorf("%q not harvested", want)
+		}
+	}
+	for _, unwanted := range []string{"pw", "cdn.example.com", "assets", "vendor", "hidden", "color", "var", "function"} {
+		if i := sort.SearchStrings(got, unwanted); i < len(got) && got[i] == unwanted {
+			t.Errorf("%q harvested", unwanted)
+		}
+	}
+}
+
+func TestHarvestContentTypes(t *testing.T) {
+	tests := []struct {
+		contentType, body string
+		want              string
+	}{
+		{"application/javascript", `fetch('/graphql/query'); const secretToken = 1;`, "fetch graphql query secretToken"},
+		{"", "<html><body>untyped page</body></html>", "page untyped"},
+		{"", "plain text words", ""},
+		{"application/json", `{"hidden": "field"}`, ""},
+		{"image/png", "<html>binary</html>", ""},
+	}
+	for _, tt := range tests {
+		h := NewHarvester(4)
+		header := http.Header{}
+		if tt.contentType != "" {
+			header.Set("Content-Type", tt.contentType)
+		}
+		h.Harvest(nil, header, []byte(tt.body))
+		if got := strings.Join(harvested(h), " "); got != tt.want {
+			t.Errorf("%q body %q harvested %q, want %q", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestHarvesterWordsAndSave(t *testing.T) {
+	h := NewHarvester(3)
+	header := http.Header{"Content-Type": {"text/html"}}
+	h.Harvest(nil, header, []byte("<p>beta alpha beta gamma ab</p>"))
+	h.Harvest(nil, header, []byte("<p>gamma beta</p>"))
+
+	words := h.Words()
+	if len(words) != 3 || h.Len() != 3 {
+		t.Fatalf("Words() = %v, Len() = %d", words, h.Len())
+	}
+	if words[0] != (HarvestedWord{"beta", 3}) || words[1] != (HarvestedWord{"gamma", 2}) || words[2] != (HarvestedWord{"alpha", 1}) {
+		t.Errorf("Words() = %v, want the most frequent first", words)
+	}
+
+	path := filepath.Join(t.TempDir(), "harvest.txt")
+	if err := h.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "beta\ngamma\nalpha\n" {
+		t.Errorf("saved %q", data)
+	}
+}
+
+func TestScanHarvestedWords(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/index":
+			w.Write([]byte(`<html><body><a href="/dashboard">panel</a></body></html>`))
+		case "/dashboard":
+			w.Write([]byte(`<html><body><div id="reports"></div></body></html>`))
+		case "/reports":
+			w.Write([]byte(`<html><body>done</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+	wordlist := writeFile(t, "words.txt", "index\nmissing\n")
+	got := scan(t, handler, Options{WordlistFile: wordlist, NoRecursion: true, Harvest: true})
+	want := "/dashboard /index /reports"
+	if strings.Join(got, " ") != want {
+		t.Errorf("found %v, want %s", got, want)
+	}
+
+	got = scan(t, handler, Options{WordlistFile: wordlist, NoRecursion: true, Harvest: true, HarvestLimit: 1})
+	if strings.Join(got, " ") != "/dashboard /index" {
+		t.Errorf("found %v with a limit of one harvested word", got)
+	}
+}
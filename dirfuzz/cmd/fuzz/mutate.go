@@ -0,0 +1,630 @@
+package fuzz
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Mutation is a chain of hashcat-style mutation functions, such as "c $1" or
+// "l sa@ A{2020..2026}". Each function works on the candidates of the one
+// before it. Most functions produce one candidate, rejection functions may
+// drop it, and the list functions fan it out.
+//
+// Supported hashcat functions:
+//
+//	:      do nothing             l, u   lowercase, uppercase
+//	c, C   capitalize, invert     t, TN  toggle case, toggle case at N
+//	r      reverse                d, pN  duplicate, append N copies
+//	f      reflect                {, }   rotate left, right
+//	$X, ^X append, prepend X      [, ]   delete first, last character
+//	DN     delete at N            xNM    extract M characters from N
+//	ONM    omit M from N          iNX    insert X at N
+//	oNX    overwrite at N with X  'N     truncate at N
+//	sXY    replace X with Y       @X     purge X
+//	zN, ZN duplicate first, last character N times
+//	q      duplicate every character
+//	<N, >N reject words longer, shorter than N
+//	_N     reject words not N long
+//	!X, /X reject words containing, not containing X
+//
+// dirfuzz extensions:
+//
+//	A{a,b}     append each of the strings, e.g. A{-dev,_old,.bak}
+//	B{a,b}     prepend each of the strings
+//	A{1990..2030} append each number of the range, zero-padded to the
+//	           width of the first bound; ranges and strings can be mixed
+//	P      pluralize              V      leetspeak (a→4, e→3, i→1, ...)
+//	S      swap separators: replace every "-", "_" and "." with each of them
+//
+// Positions N and M are 0-9 and A-Z for 10-35.
+type Mutation struct {
+	source string
+	funcs  []mutationFunc
+}
+
+// mutationFunc mutates a word into zero or more candidates.
+type mutationFunc struct {
+	apply func(word []rune) [][]rune
+	// fanout is the maximum number of candidates per word.
+	fanout int64
+}
+
+// separators are the characters the S function swaps.
+const separators = "-_."
+
+// leet maps characters to their leetspeak replacements.
+var leet = map[rune]rune{
+	'a': '4', 'A': '4', 'e': '3', 'E': '3', 'i': '1', 'I': '1',
+	'o': '0', 'O': '0', 's': '5', 'S': '5', 't': '7', 'T': '7',
+}
+
+// ParseMutation parses a rule. Spaces between functions are ignored.
+func ParseMutation(s string) (*Mutation, error) {
+	r := &Mutation{source: s}
+	p := &mutationParser{rule: []rune(s)}
+	for {
+		p.skipSpaces()
+		if p.done() {
+			break
+		}
+		op := p.next()
+		f, err := p.parseFunc(op)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %v", s, err)
+		}
+		r.funcs = append(r.funcs, f)
+	}
+	return r, nil
+}
+
+// Apply returns the candidates the rule makes of the word.
+func (r *Mutation) Apply(word string) []string {
+	candidates := [][]rune{[]rune(word)}
+	for _, f := range r.funcs {
+		var next [][]rune
+		for _, c := range candidates {
+			next = append(next, f.apply(c)...)
+		}
+		candidates = next
+	}
+
+	words := make([]string, len(candidates))
+	for i, c := range candidates {
+		words[i] = string(c)
+	}
+	return words
+}
+
+// Fanout returns the maximum number of candidates the rule makes of a word.
+func (r *Mutation) Fanout() int64 {
+	n := int64(1)
+	for _, f := range r.funcs {
+		n *= f.fanout
+	}
+	return n
+}
+
+func (r *Mutation) String() string {
+	return r.source
+}
+
+// Mutations is a set of rules. Every rule is applied to every word.
+type Mutations []*Mutation
+
+// Apply returns the candidates of all rules for the word, without
+// duplicates.
+func (rs Mutations) Apply(word string) []string {
+	var words []string
+	seen := make(map[string]bool)
+	for _, r := range rs {
+		for _, w := range r.Apply(word) {
+			if w != "" && !seen[w] {
+				seen[w] = true
+				words = append(words, w)
+			}
+		}
+	}
+	return words
+}
+
+// Fanout returns the maximum number of candidates per word.
+func (rs Mutations) Fanout() int64 {
+	var n int64
+	for _, r := range rs {
+		n += r.Fanout()
+	}
+	return n
+}
+
+// Estimate returns the maximum number of candidates the rules make of the
+// given number of words.
+func (rs Mutations) Estimate(words int64) int64 {
+	if len(rs) == 0 {
+		return words
+	}
+	return words * rs.Fanout()
+}
+
+// Chain returns the rules made of every rule of rs followed by every rule
+// of next, like several rule files given to hashcat. An empty set of rules
+// leaves the words unchanged, like the rule ":".
+func (rs Mutations) Chain(next Mutations) Mutations {
+	if len(rs) == 0 {
+		return next
+	}
+	if len(next) == 0 {
+		return rs
+	}
+	chained := make(Mutations, 0, len(rs)*len(next))
+	for _, a := range rs {
+		for _, b := range next {
+			chained = append(chained, &Mutation{
+				source: a.source + " " + b.source,
+				funcs:  append(append([]mutationFunc(nil), a.funcs...), b.funcs...),
+			})
+		}
+	}
+	return chained
+}
+
+// ParseMutations parses one rule per string.
+func ParseMutations(rules []string) (Mutations, error) {
+	var rs Mutations
+	for _, s := range rules {
+		r, err := ParseMutation(s)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+// LoadMutations reads a rule file with one rule per line. Blank lines and
+// lines starting with # are skipped.
+func LoadMutations(filename string) (Mutations, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rs Mutations
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := ParseMutation(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, n, err)
+		}
+		rs = append(rs, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// mutationParser reads the functions of a rule.
+type mutationParser struct {
+	rule []rune
+	pos  int
+}
+
+func (p *mutationParser) done() bool {
+	return p.pos >= len(p.rule)
+}
+
+func (p *mutationParser) next() rune {
+	c := p.rule[p.pos]
+	p.pos++
+	return c
+}
+
+func (p *mutationParser) skipSpaces() {
+	for !p.done() && p.rule[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// char reads a character parameter.
+func (p *mutationParser) char(op rune) (rune, error) {
+	if p.done() {
+		return 0, fmt.Errorf("missing character after %c", op)
+	}
+	return p.next(), nil
+}
+
+// position reads a position parameter.
+func (p *mutationParser) position(op rune) (int, error) {
+	if p.done() {
+		return 0, fmt.Errorf("missing position after %c", op)
+	}
+	c := p.next()
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0'), nil
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 10, nil
+	}
+	return 0, fmt.Errorf("invalid position %c after %c", c, op)
+}
+
+// list reads a brace list such as {a,b,1..3}.
+func (p *mutationParser) list(op rune) ([]string, error) {
+	p.next() // {
+	start := p.pos
+	for !p.done() && p.rule[p.pos] != '}' {
+		p.pos++
+	}
+	if p.done() {
+		return nil, fmt.Errorf("missing } after %c{", op)
+	}
+	body := string(p.rule[start:p.pos])
+	p.next() // }
+
+	var items []string
+	for _, item := range strings.Split(body, ",") {
+		lo, hi, ok := strings.Cut(item, "..")
+		if !ok {
+			items = append(items, item)
+			continue
+		}
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || from > to {
+			return nil, fmt.Errorf("invalid range %q", item)
+		}
+		for n := from; n <= to; n++ {
+			items = append(items, fmt.Sprintf("%0*d", len(lo), n))
+		}
+	}
+	return items, nil
+}
+
+// parseFunc parses the parameters of the function op.
+func (p *mutationParser) parseFunc(op rune) (mutationFunc, error) {
+	one := func(fn func(w []rune) []rune) mutationFunc {
+		return mutationFunc{fanout: 1, apply: func(w []rune) [][]rune {
+			return [][]rune{fn(w)}
+		}}
+	}
+	reject := func(keep func(w []rune) bool) mutationFunc {
+		return mutationFunc{fanout: 1, apply: func(w []rune) [][]rune {
+			if !keep(w) {
+				return nil
+			}
+			return [][]rune{w}
+		}}
+	}
+
+	switch op {
+	case ':':
+		return one(func(w []rune) []rune { return w }), nil
+	case 'l':
+		return one(func(w []rune) []rune { return []rune(strings.ToLower(string(w))) }), nil
+	case 'u':
+		return one(func(w []rune) []rune { return []rune(strings.ToUpper(string(w))) }), nil
+	case 'c', 'C':
+		return one(func(w []rune) []rune {
+			out := []rune(strings.ToLower(string(w)))
+			if op == 'C' {
+				out = []rune(strings.ToUpper(string(w)))
+			}
+			if len(out) > 0 {
+				if op == 'c' {
+					out[0] = unicode.ToUpper(out[0])
+				} else {
+					out[0] = unicode.ToLower(out[0])
+				}
+			}
+			return out
+		}), nil
+	case 't':
+		return one(func(w []rune) []rune {
+			out := make([]rune, len(w))
+			for i, c := range w {
+				out[i] = toggle(c)
+			}
+			return out
+		}), nil
+	case 'T':
+		n, err := p.position(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		return one(func(w []rune) []rune {
+			if n >= len(w) {
+				return w
+			}
+			out := append([]rune(nil), w...)
+			out[n] = toggle(out[n])
+			return out
+		}), nil
+	case 'r':
+		return one(reverse), nil
+	case 'd':
+		return one(func(w []rune) []rune { return append(append([]rune(nil), w...), w...) }), nil
+	case 'p':
+		n, err := p.position(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		return one(func(w []rune) []rune {
+			out := append([]rune(nil), w...)
+			for i := 0; i < n; i++ {
+				out = append(out, w...)
+			}
+			return out
+		}), nil
+	case 'f':
+		return one(func(w []rune) []rune { return append(append([]rune(nil), w...), reverse(w)...) }), nil
+	case '{':
+		return one(func(w []rune) []rune {
+			if len(w) == 0 {
+				return w
+			}
+			return append(append([]rune(nil), w[1:]...), w[0])
+		}), nil
+	case '}':
+		return one(func(w []rune) []rune {
+			if len(w) == 0 {
+				return w
+			}
+			return append([]rune{w[len(w)-1]}, w[:len(w)-1]...)
+		}), nil
+	case '$', '^', 'A', 'B':
+		var items []string
+		if op == 'A' || op == 'B' {
+			if p.done() || p.rule[p.pos] != '{' {
+				return mutationFunc{}, fmt.Errorf("missing { after %c", op)
+			}
+			var err error
+			if items, err = p.list(op); err != nil {
+				return mutationFunc{}, err
+			}
+		} else {
+			c, err := p.char(op)
+			if err != nil {
+				return mutationFunc{}, err
+			}
+			items = []string{string(c)}
+		}
+		return mutationFunc{fanout: int64(len(items)), apply: func(w []rune) [][]rune {
+			out := make([][]rune, 0, len(items))
+			for _, item := range items {
+				if op == '$' || op == 'A' {
+					out = append(out, []rune(string(w)+item))
+				} else {
+					out = append(out, []rune(item+string(w)))
+				}
+			}
+			return out
+		}}, nil
+	case '[':
+		return one(func(w []rune) []rune {
+			if len(w) == 0 {
+				return w
+			}
+			return w[1:]
+		}), nil
+	case ']':
+		return one(func(w []rune) []rune {
+			if len(w) == 0 {
+				return w
+			}
+			return w[:len(w)-1]
+		}), nil
+	case 'D':
+		n, err := p.position(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		return one(func(w []rune) []rune {
+			if n >= len(w) {
+				return w
+			}
+			return append(append([]rune(nil), w[:n]...), w[n+1:]...)
+		}), nil
+	case 'x', 'O':
+		n, err := p.position(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		m, err := p.position(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		return one(func(w []rune) []rune {
+			if n+m > len(w) {
+				return w
+			}
+			if op == 'x' {
+				return append([]rune(nil), w[n:n+m]...)
+			}
+			return append(append([]rune(nil), w[:n]...), w[n+m:]...)
+		}), nil
+	case 'i', 'o':
+		n, err := p.position(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		c, err := p.char(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		return one(func(w []rune) []rune {
+			if n > len(w) || (op == 'o' && n == len(w)) {
+				return w
+			}
+			out := append([]rune(nil), w[:n]...)
+			out = append(out, c)
+			if op == 'o' {
+				n++
+			}
+			return append(out, w[n:]...)
+		}), nil
+	case '\'':
+		n, err := p.position(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		return one(func(w []rune) []rune {
+			if n >= len(w) {
+				return w
+			}
+			return w[:n]
+		}), nil
+	case 's':
+		x, err := p.char(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		y, err := p.char(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		return one(func(w []rune) []rune {
+			out := make([]rune, len(w))
+			for i, c := range w {
+				if c == x {
+					c = y
+				}
+				out[i] = c
+			}
+			return out
+		}), nil
+	case '@':
+		x, err := p.char(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		return one(func(w []rune) []rune {
+			out := make([]rune, 0, len(w))
+			for _, c := range w {
+				if c != x {
+					out = append(out, c)
+				}
+			}
+			return out
+		}), nil
+	case 'z', 'Z':
+		n, err := p.position(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		return one(func(w []rune) []rune {
+			if len(w) == 0 {
+				return w
+			}
+			if op == 'z' {
+				out := []rune(strings.Repeat(string(w[0]), n))
+				return append(out, w...)
+			}
+			out := append([]rune(nil), w...)
+			return append(out, []rune(strings.Repeat(string(w[len(w)-1]), n))...)
+		}), nil
+	case 'q':
+		return one(func(w []rune) []rune {
+			out := make([]rune, 0, 2*len(w))
+			for _, c := range w {
+				out = append(out, c, c)
+			}
+			return out
+		}), nil
+	case '<', '>', '_':
+		n, err := p.position(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		return reject(func(w []rune) bool {
+			switch op {
+			case '<':
+				return len(w) <= n
+			case '>':
+				return len(w) >= n
+			}
+			return len(w) == n
+		}), nil
+	case '!', '/':
+		x, err := p.char(op)
+		if err != nil {
+			return mutationFunc{}, err
+		}
+		return reject(func(w []rune) bool {
+			return strings.ContainsRune(string(w), x) == (op == '/')
+		}), nil
+	case 'P':
+		return one(pluralize), nil
+	case 'V':
+		return one(func(w []rune) []rune {
+			out := make([]rune, len(w))
+			for i, c := range w {
+				if l, ok := leet[c]; ok {
+					c = l
+				}
+				out[i] = c
+			}
+			return out
+		}), nil
+	case 'S':
+		return mutationFunc{fanout: int64(len(separators)), apply: func(w []rune) [][]rune {
+			if !strings.ContainsAny(string(w), separators) {
+				return [][]rune{w}
+			}
+			out := make([][]rune, 0, len(separators))
+			for _, sep := range separators {
+				swapped := make([]rune, len(w))
+				for i, c := range w {
+					if strings.ContainsRune(separators, c) {
+						c = sep
+					}
+					swapped[i] = c
+				}
+				out = append(out, swapped)
+			}
+			return out
+		}}, nil
+	}
+	return mutationFunc{}, fmt.Errorf("unknown function %c", op)
+}
+
+// toggle switches the case of a letter.
+func toggle(c rune) rune {
+	if unicode.IsUpper(c) {
+		return unicode.ToLower(c)
+	}
+	return unicode.ToUpper(c)
+}
+
+// reverse returns the characters in reverse order.
+func reverse(w []rune) []rune {
+	out := make([]rune, len(w))
+	for i, c := range w {
+		out[len(w)-1-i] = c
+	}
+	return out
+}
+
+// pluralize returns the English plural of a word.
+func pluralize(w []rune) []rune {
+	s := string(w)
+	lower := strings.ToLower(s)
+	switch {
+	case s == "":
+		return w
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return []rune(s + "es")
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return []rune(s[:len(s)-1] + "ies")
+	}
+	return []rune(s + "s")
+}
@@ -0,0 +1,156 @@
+package fuzz
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMutationApply(t *testing.T) {
+	tests := []struct {
+		rule, word string
+		want       []string
+	}{
+		{":", "Admin", []string{"Admin"}},
+		{"l", "AdMin", []string{"admin"}},
+		{"u", "admin", []string{"ADMIN"}},
+		{"c", "aDMIN", []string{"Admin"}},
+		{"C", "admin", []string{"aDMIN"}},
+		{"t", "aDmin", []string{"AdMIN"}},
+		{"T1", "admin", []string{"aDmin"}},
+		{"T9", "admin", []string{"admin"}},
+		{"r", "admin", []string{"nimda"}},
+		{"d", "ab", []string{"abab"}},
+		{"p2", "ab", []string{"ababab"}},
+		{"f", "ab", []string{"abba"}},
+		{"{", "admin", []string{"dmina"}},
+		{"}", "admin", []string{"nadmi"}},
+		{"$1 $2", "admin", []string{"admin12"}},
+		{"^1 ^2", "admin", []string{"21admin"}},
+		// Hashcat appends and prepends a literal brace.
+		{"${", "admin", []string{"admin{"}},
+		{"^}", "admin", []string{"}admin"}},
+		{"[", "admin", []string{"dmin"}},
+		{"]", "admin", []string{"admi"}},
+		{"D1", "admin", []string{"amin"}},
+		{"x12", "admin", []string{"dm"}},
+		{"O12", "admin", []string{"ain"}},
+		{"i1-", "admin", []string{"a-dmin"}},
+		{"o0A", "admin", []string{"Admin"}},
+		{"'3", "admin", []string{"adm"}},
+		{"sa@", "banana", []string{"b@n@n@"}},
+		{"@a", "banana", []string{"bnn"}},
+		{"z2", "ab", []string{"aaab"}},
+		{"Z2", "ab", []string{"abbb"}},
+		{"q", "ab", []string{"aabb"}},
+		{"<5", "admin", []string{"admin"}},
+		{"<4", "admin", nil},
+		{">6", "admin", nil},
+		{"_5", "admin", []string{"admin"}},
+		{"!m", "admin", nil},
+		{"/x", "admin", nil},
+		{"P", "entry", []string{"entries"}},
+		{"P", "box", []string{"boxes"}},
+		{"P", "day", []string{"days"}},
+		{"V", "site", []string{"5173"}},
+		{"S", "old-site", []string{"old-site", "old_site", "old.site"}},
+		{"S", "site", []string{"site"}},
+		{"A{-dev,.bak}", "app", []string{"app-dev", "app.bak"}},
+		{"B{old_,new_}", "app", []string{"old_app", "new_app"}},
+		{"A{08..10}", "v", []string{"v08", "v09", "v10"}},
+		{"A{x,1..2}", "v", []string{"vx", "v1", "v2"}},
+		{"c A{1,2} $!", "app", []string{"App1!", "App2!"}},
+		{"S A{1,2}", "a-b", []string{"a-b1", "a-b2", "a_b1", "a_b2", "a.b1", "a.b2"}},
+	}
+	for _, tt := range tests {
+		r, err := ParseMutation(tt.rule)
+		if err != nil {
+			t.Errorf("ParseMutation(%q): %v", tt.rule, err)
+			continue
+		}
+		got := r.Apply(tt.word)
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%q applied to %q = %q, want %q", tt.rule, tt.word, got, tt.want)
+		}
+		if n := int64(len(got)); n > r.Fanout() {
+			t.Errorf("%q made %d candidates, more than its fanout %d", tt.rule, n, r.Fanout())
+		}
+	}
+}
+
+func TestParseMutationErrors(t *testing.T) {
+	for _, rule := range []string{"$", "T", "Tz", "s", "sa", "A", "A1", "A{1,2", "A{3..1}", "B{", "w"} {
+		if _, err := ParseMutation(rule); err == nil {
+			t.Errorf("ParseMutation(%q) succeeded", rule)
+		}
+	}
+}
+
+func TestMutationsApplyDedupes(t *testing.T) {
+	rs, err := ParseMutations([]string{":", "l", "u"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := rs.Apply("admin")
+	if strings.Join(got, " ") != "admin ADMIN" {
+		t.Errorf("Apply() = %q", got)
+	}
+	if rs.Estimate(10) != 30 {
+		t.Errorf("Estimate(10) = %d, want 30", rs.Estimate(10))
+	}
+	if Mutations(nil).Estimate(10) != 10 {
+		t.Errorf("Estimate without rules = %d, want 10", Mutations(nil).Estimate(10))
+	}
+}
+
+func TestMutationsChain(t *testing.T) {
+	first, _ := ParseMutations([]string{"l", "u"})
+	second, _ := ParseMutations([]string{"$1", "A{2,3}"})
+	chained := first.Chain(second)
+	got := chained.Apply("Admin")
+	want := "admin1 admin2 admin3 ADMIN1 ADMIN2 ADMIN3"
+	if strings.Join(got, " ") != want {
+		t.Errorf("chained rules made %q, want %q", got, want)
+	}
+	if chained.Fanout() != 6 {
+		t.Errorf("Fanout() = %d, want 6", chained.Fanout())
+	}
+
+	// An empty rule file leaves the words unchanged.
+	for _, rs := range []Mutations{first.Chain(nil), Mutations(nil).Chain(first)} {
+		if got := rs.Apply("Admin"); strings.Join(got, " ") != "admin ADMIN" {
+			t.Errorf("chained with no rules: %q", got)
+		}
+	}
+}
+
+func TestLoadMutations(t *testing.T) {
+	path := writeFile(t, "rules.txt", "# years\r\nA{2023..2024}\n\n  \nc\n")
+	rs, err := LoadMutations(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rs.Apply("app"); strings.Join(got, " ") != "app2023 app2024 App" {
+		t.Errorf("loaded rules made %q", got)
+	}
+
+	path = writeFile(t, "bad.txt", "c\nA{1\n")
+	if _, err := LoadMutations(path); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("LoadMutations error = %v, want the line number", err)
+	}
+
+	empty := writeFile(t, "empty.txt", "# nothing\n")
+	rules, err := (&Options{RuleFiles: []string{empty}, Rules: []string{"u"}}).newRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rules.Apply("app"); strings.Join(got, " ") != "APP" {
+		t.Errorf("an empty rule file and u made %q", got)
+	}
+	rules, err = (&Options{RuleFiles: []string{writeFile(t, "c.txt", "c\n"), empty}}).newRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rules.Apply("app"); strings.Join(got, " ") != "App" {
+		t.Errorf("c and an empty rule file made %q", got)
+	}
+}
@@ -0,0 +1,275 @@
+package fuzz
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// ntlmTransport answers NTLM challenges. NTLM authenticates a connection
+// rather than a request, so the three messages of the handshake have to be
+// sent on the same connection. Every request takes a transport with a single
+// connection per host from the pool and returns it once its body is closed;
+// later requests on an authenticated connection need no handshake.
+type ntlmTransport struct {
+	credentials *credentials
+	pool        chan *http.Transport
+}
+
+func newNTLMTransport(c *credentials, size int, newBase func() *http.Transport) *ntlmTransport {
+	if size < 1 {
+		size = 1
+	}
+	t := &ntlmTransport{credentials: c, pool: make(chan *http.Transport, size)}
+	for i := 0; i < size; i++ {
+		base := newBase()
+		base.MaxConnsPerHost = 1
+		base.MaxIdleConnsPerHost = 1
+		t.pool <- base
+	}
+	return t
+}
+
+func (t *ntlmTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var base *http.Transport
+	select {
+	case base = <-t.pool:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	var once sync.Once
+	release := func() {
+		once.Do(func() { t.pool <- base })
+	}
+
+	resp, err := t.roundTrip(base, req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// roundTrip sends the request and, if the server asks for NTLM, does the
+// handshake and sends it again.
+func (t *ntlmTransport) roundTrip(base *http.Transport, req *http.Request) (*http.Response, error) {
+	resp, err := base.RoundTrip(req.Clone(req.Context()))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// IIS offers NTLM on its own or inside Negotiate, which also takes bare
+	// NTLM messages.
+	scheme := ""
+	for _, c := range parseChallenges(resp.Header.Values("Www-Authenticate")) {
+		if c.scheme == "ntlm" || c.scheme == "negotiate" && scheme == "" {
+			scheme = c.scheme
+		}
+	}
+	negotiate := rewind(req)
+	if scheme == "" || negotiate == nil {
+		return resp, nil
+	}
+	drain(resp)
+	if scheme == "ntlm" {
+		scheme = "NTLM"
+	} else {
+		scheme = "Negotiate"
+	}
+
+	negotiate.Header.Set("Authorization", scheme+" "+base64.StdEncoding.EncodeToString(ntlmNegotiate()))
+	resp, err = base.RoundTrip(negotiate)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	var challenge []byte
+	for _, c := range parseChallenges(resp.Header.Values("Www-Authenticate")) {
+		if strings.EqualFold(c.scheme, scheme) && c.token != "" {
+			challenge, _ = base64.StdEncoding.DecodeString(c.token)
+		}
+	}
+	authenticate, err := ntlmAuthenticate(t.credentials, challenge)
+	retry := rewind(req)
+	if err != nil || retry == nil {
+		return resp, nil
+	}
+	drain(resp)
+
+	retry.Header.Set("Authorization", scheme+" "+base64.StdEncoding.EncodeToString(authenticate))
+	return base.RoundTrip(retry)
+}
+
+// releaseBody returns the transport of a response to the pool when the
+// body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// NTLM negotiate flags, see MS-NLMP 2.2.2.5.
+const (
+	ntlmNegotiateUnicode     = 0x00000001
+	ntlmRequestTarget        = 0x00000004
+	ntlmNegotiateNTLM        = 0x00000200
+	ntlmNegotiateAlwaysSign  = 0x00008000
+	ntlmNegotiateExtendedSec = 0x00080000
+	ntlmNegotiate128         = 0x20000000
+	ntlmNegotiateKeyExchange = 0x40000000
+	ntlmNegotiate56          = 0x80000000
+
+	ntlmNegotiateFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM |
+		ntlmNegotiateAlwaysSign | ntlmNegotiateExtendedSec | ntlmNegotiate128 | ntlmNegotiate56
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmNegotiate returns the NEGOTIATE_MESSAGE that starts the handshake,
+// without a domain or workstation.
+func ntlmNegotiate() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmNegotiateFlags)
+	return msg
+}
+
+// ntlmAuthenticate returns the AUTHENTICATE_MESSAGE answering the
+// CHALLENGE_MESSAGE of the server with an NTLMv2 response, with a random
+// client challenge.
+func ntlmAuthenticate(c *credentials, challenge []byte) ([]byte, error) {
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+	return ntlmAuthenticateAt(c, challenge, clientChallenge, ntlmFiletime(time.Now()))
+}
+
+// ntlmAuthenticateAt returns the AUTHENTICATE_MESSAGE with the client
+// challenge. The timestamp of the server is used if it sends one, else now.
+func ntlmAuthenticateAt(c *credentials, challenge, clientChallenge, now []byte) ([]byte, error) {
+	if len(challenge) < 48 || !bytes.Equal(challenge[:8], ntlmSignature) || binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, errors.New("invalid NTLM challenge")
+	}
+	flags := binary.LittleEndian.Uint32(challenge[20:])
+	serverChallenge := challenge[24:32]
+	targetInfo, ok := ntlmField(challenge, 40)
+	if !ok {
+		return nil, errors.New("invalid NTLM challenge")
+	}
+
+	timestamp, hasTimestamp := ntlmTimestamp(targetInfo)
+	if !hasTimestamp {
+		timestamp = now
+	}
+
+	key := ntowfv2(c.user, c.password, c.domain)
+	nt := ntlmv2Response(key, serverChallenge, clientChallenge, timestamp, targetInfo)
+	// With a timestamp from the server, the LMv2 response is left empty.
+	lm := make([]byte, 24)
+	if !hasTimestamp {
+		mac := hmac.New(md5.New, key)
+		mac.Write(serverChallenge)
+		mac.Write(clientChallenge)
+		lm = append(mac.Sum(nil), clientChallenge...)
+	}
+
+	// No session key is exchanged.
+	flags = flags&^ntlmNegotiateKeyExchange | ntlmNegotiateUnicode
+	fields := [][]byte{lm, nt, utf16le(c.domain), utf16le(c.user), nil, nil}
+	msg := make([]byte, 64)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	binary.LittleEndian.PutUint32(msg[60:], flags)
+	for i, field := range fields {
+		header := msg[12+8*i:]
+		binary.LittleEndian.PutUint16(header, uint16(len(field)))
+		binary.LittleEndian.PutUint16(header[2:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(header[4:], uint32(len(msg)))
+		msg = append(msg, field...)
+	}
+	return msg, nil
+}
+
+// ntowfv2 returns the NTLMv2 key of the credentials.
+func ntowfv2(user, password, domain string) []byte {
+	hash := md4.New()
+	hash.Write(utf16le(password))
+	mac := hmac.New(md5.New, hash.Sum(nil))
+	mac.Write(utf16le(strings.ToUpper(user) + domain))
+	return mac.Sum(nil)
+}
+
+// ntlmv2Response returns the NTProofStr followed by the client blob it
+// signs, see MS-NLMP 3.3.2.
+func ntlmv2Response(key, serverChallenge, clientChallenge, timestamp, targetInfo []byte) []byte {
+	blob := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	blob = append(blob, timestamp...)
+	blob = append(blob, clientChallenge...)
+	blob = append(blob, 0, 0, 0, 0)
+	blob = append(blob, targetInfo...)
+	blob = append(blob, 0, 0, 0, 0)
+
+	mac := hmac.New(md5.New, key)
+	mac.Write(serverChallenge)
+	mac.Write(blob)
+	return append(mac.Sum(nil), blob...)
+}
+
+// ntlmField returns the payload a field header at offset points to.
+func ntlmField(msg []byte, offset int) ([]byte, bool) {
+	n := int(binary.LittleEndian.Uint16(msg[offset:]))
+	start := int(binary.LittleEndian.Uint32(msg[offset+4:]))
+	if start+n > len(msg) || start+n < start {
+		return nil, false
+	}
+	return msg[start : start+n], true
+}
+
+// ntlmTimestamp returns the MsvAvTimestamp of the target info, if any.
+func ntlmTimestamp(targetInfo []byte) ([]byte, bool) {
+	for len(targetInfo) >= 4 {
+		id := binary.LittleEndian.Uint16(targetInfo)
+		n := int(binary.LittleEndian.Uint16(targetInfo[2:]))
+		if id == 0 || len(targetInfo) < 4+n {
+			break
+		}
+		if id == 7 && n == 8 {
+			return targetInfo[4:12], true
+		}
+		targetInfo = targetInfo[4+n:]
+	}
+	return nil, false
+}
+
+// ntlmFiletime returns t in 100ns intervals since 1601, little endian.
+func ntlmFiletime(t time.Time) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(t.UnixNano()/100+116444736000000000))
+	return b
+}
+
+func utf16le(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return b
+}
@@ -0,0 +1,447 @@
+package fuzz
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/your-username/dirfuzz/wordlist"
+)
+
+// Options holds the settings of a scan.
+type Options struct {
+	// TargetURL is the URL to scan. Without the FUZZ keyword in the URL,
+	// headers or body, payloads are appended to it as paths. With a
+	// RequestFile, it only sets the scheme and host to connect to.
+	TargetURL    string
+	WordlistFile string
+	// Wordlists are given as "path" or "path:KEYWORD" and combined as
+	// described by Mode. The keyword defaults to FUZZ.
+	Wordlists []string
+	// Mode is clusterbomb (default), pitchfork or sniper.
+	Mode string
+	// Dedupe is how repeated wordlist entries are dropped: set (default),
+	// bloom or none.
+	Dedupe       string
+	Threads      int
+	Timeout      int
+	Extensions   string
+	IgnoreRegex  string
+	OutputFile   string
+	OutputFormat string
+
+	// HTTPVersion forces HTTP/1.0 or HTTP/1.1, or enables HTTP/2 over TLS
+	// with "2". The default is HTTP/1.1. Timeout applies separately to
+	// dialing, the TLS handshake, waiting for the headers and reading the
+	// body. DisableCompression stops asking for compressed responses, so
+	// sizes are the raw sizes.
+	HTTPVersion        string
+	DisableCompression bool
+
+	// Proxies are upstream proxies as "http://", "https://" or "socks5://"
+	// URLs with optional "user:pass@" credentials, used in turn. A fraction
+	// ProxySample of the requests goes through them, the others directly;
+	// zero means every request. ReplayProxy receives a copy of every hit,
+	// such as Burp at http://127.0.0.1:8080.
+	Proxies     []string
+	ProxySample float64
+	ReplayProxy string
+
+	// Insecure skips the verification of server certificates, CACertFile
+	// verifies them against a PEM bundle instead of the system roots.
+	// ClientCertFile is a PEM certificate, with its key in ClientKeyFile or
+	// in the same file, or a PKCS#12 file protected by ClientCertPassword.
+	// SNI is the server name sent in the handshake and verified, regardless
+	// of the Host header. TLSMinVersion and TLSMaxVersion are versions such
+	// as "1.2", and CipherSuites are Go cipher suite names, which only
+	// apply up to TLS 1.2.
+	Insecure           bool
+	CACertFile         string
+	ClientCertFile     string
+	ClientKeyFile      string
+	ClientCertPassword string
+	SNI                string
+	TLSMinVersion      string
+	TLSMaxVersion      string
+	CipherSuites       []string
+
+	// ExtensionsNoDot only appends Extensions to entries without a dot.
+	ExtensionsNoDot bool
+
+	// RuleFiles are hashcat-style rule files applied to every wordlist
+	// entry. Several files are chained: each rule of the first is followed
+	// by each rule of the next. Rules are chained after the files.
+	RuleFiles []string
+	Rules     []string
+	// MaxRequests aborts the scan if the estimated number of requests per
+	// base exceeds it. Zero means no limit.
+	MaxRequests int64
+
+	// Encodings are encoder chains given as "chain" or "chain:KEYWORD",
+	// such as "url,base64". Each payload of the keyword is passed through
+	// its chain before it is substituted. The keyword defaults to FUZZ.
+	Encodings []string
+
+	// Method, Headers ("Name: value"), Cookie and Data make up the request
+	// template. Each of them may contain the FUZZ keyword.
+	Method  string
+	Headers []string
+	Cookie  string
+	Data    string
+	// RequestFile is a raw HTTP request, as copied from Burp, used as the
+	// template instead of TargetURL, Method and Data. Headers and Cookie
+	// are added to it. RequestScheme is the scheme to send it with unless
+	// TargetURL is given: https (default) or http.
+	RequestFile   string
+	RequestScheme string
+
+	// CookieJar keeps the cookies the target sets, shared by all workers.
+	CookieJar bool
+	// LoginRequestFile is a raw HTTP request that logs in before the scan
+	// and again whenever a response matches the LoggedOut rule (see
+	// ParseRule), such as "status:401 || header:Location~/login". It
+	// enables the cookie jar. LoginExtract takes a token from the login
+	// response, as "regex:PATTERN" (the first group) or "json:PATH" (a
+	// JSONPath such as $.data.token), and LoginInject sends it as a header
+	// template (default "Authorization: Bearer {token}") or "cookie:NAME".
+	LoginRequestFile string
+	LoginExtract     string
+	LoginInject      string
+	LoggedOut        string
+	// Auth is the HTTP authentication as "basic:USER:PASS",
+	// "digest:USER:PASS", "bearer:TOKEN" or "ntlm:[DOMAIN\\]USER:PASS".
+	// Requests that already have an Authorization header, from Headers,
+	// the raw request or the login token, are sent with it instead.
+	Auth string
+
+	// NoRecursion disables scanning below discovered directories.
+	NoRecursion bool
+	// MaxDepth limits how many directory levels below the target are
+	// scanned. Zero means no limit.
+	MaxDepth int
+	// IgnoreDir lists directories that are reported but never recursed into.
+	IgnoreDir []string
+	// NoCalibration disables the soft-404 calibration of each base.
+	NoCalibration bool
+
+	// Matchers and Filters are rules of the filter engine, e.g.
+	// "status:200-299 && !size:0". A response is kept if the matchers hold
+	// and the filters do not. Without matchers, everything but 404 is kept.
+	Matchers []string
+	Filters  []string
+	// MatchMode and FilterMode combine several rules with "or" or "and".
+	MatchMode  string
+	FilterMode string
+	// IgnorePayloads lists wordlist entries that are never sent.
+	IgnorePayloads []string
+
+	// Harvest collects words from the pages of hits and tries those that
+	// are not in the FUZZ wordlist at every base, up to HarvestLimit words
+	// per base (zero means no limit). HarvestOutput saves the words, most
+	// frequent first. Words shorter than HarvestMinLength are dropped.
+	Harvest          bool
+	HarvestOutput    string
+	HarvestMinLength int
+	HarvestLimit     int
+
+	// Backups probes backup and temporary file variants of every found
+	// file and directory, such as config.php.bak or admin.zip, using the
+	// templates of BackupTemplateFile or DefaultBackupTemplates. Giving a
+	// template file enables it.
+	Backups            bool
+	BackupTemplateFile string
+
+	// Anomaly reports responses that are more than AnomalyDeviations
+	// standard deviations slower than the running average, even if the
+	// filters would drop them.
+	Anomaly           bool
+	AnomalyDeviations float64
+}
+
+// Validate checks the options for missing or invalid values. The request
+// files, rule files and backup templates are loaded, and checked, by
+// NewScanner.
+func (o *Options) Validate() error {
+	if o.TargetURL == "" && o.RequestFile == "" {
+		return errors.New("no target URL given")
+	}
+	if o.TargetURL != "" {
+		u, err := url.Parse(o.TargetURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.New("invalid target URL: " + o.TargetURL)
+		}
+	}
+	switch o.RequestScheme {
+	case "", "http", "https":
+	default:
+		return fmt.Errorf("invalid request scheme %q (want http or https)", o.RequestScheme)
+	}
+	lists := o.wordlists()
+	if err := validateWordlists(o.Mode, lists); err != nil {
+		return err
+	}
+	if err := validateDedupe(o.Dedupe); err != nil {
+		return err
+	}
+	if err := validateHTTPVersion(o.HTTPVersion); err != nil {
+		return err
+	}
+	if _, err := parseProxies(o.Proxies); err != nil {
+		return err
+	}
+	if o.ProxySample < 0 || o.ProxySample > 1 {
+		return errors.New("proxy sample must be between 0 and 1")
+	}
+	if err := validateTLS(*o); err != nil {
+		return err
+	}
+	if o.ReplayProxy != "" {
+		if _, err := parseProxy(o.ReplayProxy); err != nil {
+			return err
+		}
+	}
+	if err := validateAuth(*o); err != nil {
+		return err
+	}
+	if o.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	if o.Threads < 1 {
+		return errors.New("threads must be at least 1")
+	}
+	if o.MaxDepth < 0 {
+		return errors.New("max depth must not be negative")
+	}
+	if o.MaxRequests < 0 {
+		return errors.New("max requests must not be negative")
+	}
+	if o.Harvest && !hasKeyword(lists, Keyword) {
+		return errors.New("harvesting needs a wordlist bound to " + Keyword)
+	}
+	if o.HarvestLimit < 0 {
+		return errors.New("harvest limit must not be negative")
+	}
+	if o.backups() && !hasKeyword(lists, Keyword) {
+		return errors.New("probing backups needs a wordlist bound to " + Keyword)
+	}
+	if o.Anomaly && o.AnomalyDeviations <= 0 {
+		return errors.New("anomaly deviations must be positive")
+	}
+	return nil
+}
+
+// wordlists returns the wordlists with their keywords. Without wordlists,
+// the default builtin list is used.
+func (o *Options) wordlists() []Wordlist {
+	specs := o.Wordlists
+	if o.WordlistFile != "" {
+		specs = append([]string{o.WordlistFile}, specs...)
+	}
+	if len(specs) == 0 {
+		specs = []string{wordlist.Prefix + wordlist.Default}
+	}
+
+	lists := make([]Wordlist, 0, len(specs))
+	for _, spec := range specs {
+		lists = append(lists, ParseWordlist(spec))
+	}
+	return lists
+}
+
+// newRules loads the rule files and chains them with the inline rules.
+func (o *Options) newRules() (Mutations, error) {
+	var rules Mutations
+	for _, file := range o.RuleFiles {
+		rs, err := LoadMutations(file)
+		if err != nil {
+			return nil, err
+		}
+		rules = rules.Chain(rs)
+	}
+	if len(o.Rules) > 0 {
+		rs, err := ParseMutations(o.Rules)
+		if err != nil {
+			return nil, err
+		}
+		rules = rules.Chain(rs)
+	}
+	return rules, nil
+}
+
+// newEncoders parses the encoder chains by keyword. Every keyword must be
+// bound to a wordlist and have at most one chain.
+func (o *Options) newEncoders(lists []Wordlist) (map[string]Encoders, error) {
+	chains := make(map[string]Encoders, len(o.Encodings))
+	for _, spec := range o.Encodings {
+		keyword, e, err := ParseEncoding(spec)
+		if err != nil {
+			return nil, err
+		}
+		if !hasKeyword(lists, keyword) {
+			return nil, fmt.Errorf("encoder chain %q: no wordlist is bound to %s", spec, keyword)
+		}
+		if _, ok := chains[keyword]; ok {
+			return nil, fmt.Errorf("more than one encoder chain for %s", keyword)
+		}
+		chains[keyword] = e
+	}
+	return chains, nil
+}
+
+// backups reports whether backup variants are probed.
+func (o *Options) backups() bool {
+	return o.Backups || o.BackupTemplateFile != ""
+}
+
+// newBackupTemplates loads the backup template file, or parses the default
+// templates without one. It returns nil if backups are not probed.
+func (o *Options) newBackupTemplates() (BackupTemplates, error) {
+	if !o.backups() {
+		return nil, nil
+	}
+	if o.BackupTemplateFile != "" {
+		return LoadBackupTemplates(o.BackupTemplateFile)
+	}
+	return ParseBackupTemplates(DefaultBackupTemplates)
+}
+
+// newTemplate builds the request template. If the FUZZ keyword is bound to
+// a wordlist but appears nowhere, it is appended to the target URL as the
+// last path segment. Every other keyword has to appear in the request.
+func (o *Options) newTemplate(lists []Wordlist) (*Request, error) {
+	var r *Request
+	if o.RequestFile != "" {
+		var err error
+		if r, err = o.loadRawRequest(o.RequestFile); err != nil {
+			return nil, err
+		}
+	} else {
+		r = &Request{
+			Method: o.Method,
+			URL:    o.TargetURL,
+			Header: make(http.Header),
+		}
+		if r.Method == "" {
+			r.Method = http.MethodGet
+		}
+		if o.Data != "" {
+			r.Body = []byte(o.Data)
+		}
+	}
+	for _, header := range o.Headers {
+		name, value, ok := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q (want \"Name: value\")", header)
+		}
+		r.Header[name] = append(r.Header[name], strings.TrimSpace(value))
+		if r.HeaderOrder != nil {
+			r.HeaderOrder = append(r.HeaderOrder, name)
+		}
+	}
+	if o.Cookie != "" {
+		// A raw request may spell the header in any case.
+		for name := range r.Header {
+			if strings.EqualFold(name, "Cookie") {
+				delete(r.Header, name)
+			}
+		}
+		r.Header.Set("Cookie", o.Cookie)
+	}
+
+	for _, list := range lists {
+		if r.HasKeyword(list.Keyword) {
+			continue
+		}
+		if list.Keyword != Keyword {
+			return nil, fmt.Errorf("keyword %s does not appear in the request", list.Keyword)
+		}
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target URL: %v", err)
+		}
+		r.URL = appendPath(u, Keyword).String()
+	}
+	return r, nil
+}
+
+// rawRequests reports whether raw request files are sent, whose header order
+// is kept.
+func (o *Options) rawRequests() bool {
+	return o.RequestFile != "" || o.LoginRequestFile != ""
+}
+
+// loadRawRequest loads a raw request file. With TargetURL, the request is
+// sent to its scheme and host, keeping the Host header of the file.
+func (o *Options) loadRawRequest(filename string) (*Request, error) {
+	scheme := o.RequestScheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	var target *url.URL
+	if o.TargetURL != "" {
+		// The URL was checked by Validate.
+		target, _ = url.Parse(o.TargetURL)
+		scheme = target.Scheme
+	}
+
+	r, err := LoadRawRequest(filename, scheme)
+	if err != nil {
+		return nil, err
+	}
+	if target != nil {
+		// The target is kept as written rather than reencoded.
+		_, rest, _ := strings.Cut(r.URL, "://")
+		path := ""
+		if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+			path = rest[i:]
+		}
+		r.URL = target.Scheme + "://" + target.Host + path
+	}
+	return r, nil
+}
+
+// newFilter builds the filter engine from the rule options.
+func (o *Options) newFilter() (*Filter, error) {
+	f := NewFilter()
+	for _, mode := range []string{o.MatchMode, o.FilterMode} {
+		if mode != "" && mode != ModeOr && mode != ModeAnd {
+			return nil, fmt.Errorf("invalid rule mode %q (want %s or %s)", mode, ModeOr, ModeAnd)
+		}
+	}
+	if o.MatchMode != "" {
+		f.MatchMode = o.MatchMode
+	}
+	if o.FilterMode != "" {
+		f.FilterMode = o.FilterMode
+	}
+
+	matchers := o.Matchers
+	if len(matchers) == 0 {
+		matchers = []string{"!status:404"}
+	}
+	for _, rule := range matchers {
+		if err := f.AddMatcher(rule); err != nil {
+			return nil, err
+		}
+	}
+	for _, rule := range o.Filters {
+		if err := f.AddFilter(rule); err != nil {
+			return nil, err
+		}
+	}
+	// The pattern is not a rule, so || and ! in it are regex syntax.
+	if o.IgnoreRegex != "" {
+		re, err := regexp.Compile(o.IgnoreRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore regex: %v", err)
+		}
+		f.Filters = append(f.Filters, regexCriterion{re})
+	}
+	f.Blacklist(o.IgnorePayloads...)
+
+	return f, nil
+}
@@ -1,70 +1,383 @@
-package main
+package fuzz
 
 import (
-    "bufio"
-    "compress/gzip"
-    "fmt"
-    "io/ioutil"
-    "os"
-    "path/filepath"
-    "strings"
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+
+	"github.com/your-username/dirfuzz/wordlist"
+)
+
+// 各种格式的魔数，用于识别文件格式，与扩展名无关
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	zipMagic   = []byte("PK\x03\x04")
+	tarMagic   = []byte("ustar")
 )
 
+// tar文件头中魔数的偏移
+const tarMagicOffset = 257
+
 const (
-    // 文件的压缩格式
-    GZIP_EXT = ".gz"
+	// 文件的压缩格式
+	//
+	// Deprecated: 文件格式按魔数识别，与扩展名无关。
+	GZIP_EXT = ".gz"
 )
 
+// 压缩文件的常见扩展名，用于在目录中查找字典文件
+var compressedExts = []string{".gz", ".zst", ".bz2", ".xz"}
+
 // 读取文件并将其返回为字符串切片。
+// 大文件请使用Dictionary流式读取。
 func ReadPayloadFile(filename string) ([]string, error) {
-    // 确定文件的绝对路径。
-    absPath, err := filepath.Abs(filename)
-    if err != nil {
-        return nil, fmt.Errorf("failed to get absolute path for %s: %v", filename, err)
-    }
-
-    // 检查文件是否存在。
-    _, err = os.Stat(absPath)
-    if err != nil {
-        return nil, fmt.Errorf("failed to read payload file %s: %v", absPath, err)
-    }
-
-    // 读取文件内容。
-    file, err := os.Open(absPath)
-    if err != nil {
-        return nil, fmt.Errorf("failed to open payload file %s: %v", absPath, err)
-    }
-
-    defer file.Close()
-
-    // 如果文件是Gzip压缩的，则使用Gzip解压缩。
-    if strings.HasSuffix(strings.ToLower(absPath), GZIP_EXT) {
-        gz, err := gzip.NewReader(file)
-        if err != nil {
-            return nil, fmt.Errorf("failed to create gzip reader for %s: %v", absPath, err)
-        }
-        defer gz.Close()
-
-        reader := bufio.NewReader(gz)
-
-        // 逐行读取文件内容。
-        var lines []string
-        for {
-            line, err := reader.ReadString('\n')
-            if err != nil {
-                break
-            }
-            lines = append(lines, strings.TrimRight(line, "\r\n"))
-        }
-
-        return lines, nil
-    }
-
-    // 如果文件不是Gzip压缩的，则直接读取文件内容。
-    content, err := ioutil.ReadAll(file)
-    if err != nil {
-        return nil, fmt.Errorf("failed to read payload file %s: %v", absPath, err)
-    }
-
-    return strings.Split(strings.TrimSpace(string(content)), "\n"), nil
+	file, err := OpenPayloadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// 逐行读取文件内容。
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read payload file %s: %v", filename, err)
+	}
+
+	return lines, nil
+}
+
+// 打开文件并返回其解压后的内容。文件名为"-"时读取标准输入。
+// gzip、bzip2、xz和zstd压缩的文件按魔数识别并在读取时解压。
+// zip和tar归档（包括压缩的tar）中所有文件的内容依次连接，
+// 也可以用"归档:模式"只选择匹配的条目，例如
+// "seclists.zip:Discovery/Web-Content/*.txt"。内容不会解压到磁盘。
+// "builtin:名称"打开内置字典，"range:1-100"等生成器的载荷逐行返回。
+func OpenPayloadFile(filename string) (io.ReadCloser, error) {
+	if list, ok := wordlist.IsBuiltin(filename); ok {
+		return wordlist.Open(list)
+	}
+	if isGenerator(filename) {
+		return openGenerator(filename)
+	}
+
+	name, pattern := splitArchivePath(filename)
+	if pattern != "" {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid archive pattern %q: %v", pattern, err)
+		}
+	}
+
+	if name == Stdin {
+		rc, err := openPayload(os.Stdin, nil, pattern, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read payloads from stdin: %v", err)
+		}
+		return rc, nil
+	}
+
+	// 确定文件的绝对路径。
+	absPath, err := filepath.Abs(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for %s: %v", name, err)
+	}
+
+	// 打开文件。
+	file, err := os.Open(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open payload file %s: %v", absPath, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read payload file %s: %v", absPath, err)
+	}
+
+	rc, err := openPayload(file, io.NewSectionReader(file, 0, info.Size()), pattern, file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read payload file %s: %v", absPath, err)
+	}
+	return rc, nil
+}
+
+// splitArchivePath把"归档:模式"拆分为归档路径和条目模式。
+// 冒号前的部分必须是已存在的文件，因此含冒号的普通路径不受影响。
+func splitArchivePath(filename string) (string, string) {
+	if strings.HasPrefix(filename, Stdin+":") {
+		return Stdin, filename[len(Stdin)+1:]
+	}
+	if _, err := os.Stat(filename); err == nil {
+		return filename, ""
+	}
+	for i := 0; i < len(filename); i++ {
+		if filename[i] != ':' {
+			continue
+		}
+		if info, err := os.Stat(filename[:i]); err == nil && info.Mode().IsRegular() {
+			return filename[:i], filename[i+1:]
+		}
+	}
+	return filename, ""
+}
+
+// openPayload按魔数识别r的格式并返回解压后的内容。
+// ra不为nil时可以读取zip归档，closer在返回的内容关闭时一并关闭。
+func openPayload(r io.Reader, ra *io.SectionReader, pattern string, closer io.Closer) (io.ReadCloser, error) {
+	rc := &payloadReader{}
+	if closer != nil {
+		rc.closers = append(rc.closers, closer.Close)
+	}
+
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zipMagic))
+	if bytes.HasPrefix(magic, zipMagic) {
+		if ra == nil {
+			return nil, errors.New("zip archives cannot be read from a stream")
+		}
+		zr, err := zip.NewReader(ra, ra.Size())
+		if err != nil {
+			return nil, err
+		}
+		rc.Reader = newZipReader(zr, pattern)
+		return rc, nil
+	}
+
+	// 解压缩
+	d, err := decompress(br, rc)
+	if err != nil {
+		return nil, err
+	}
+
+	// 解压后的内容可能是tar归档
+	dr := bufio.NewReader(d)
+	header, _ := dr.Peek(tarMagicOffset + len(tarMagic))
+	if len(header) == tarMagicOffset+len(tarMagic) && bytes.Equal(header[tarMagicOffset:], tarMagic) {
+		rc.Reader = newTarReader(tar.NewReader(dr), pattern)
+		return rc, nil
+	}
+
+	if pattern != "" {
+		return nil, fmt.Errorf("cannot select %q: not a zip or tar archive", pattern)
+	}
+	rc.Reader = dr
+	return rc, nil
+}
+
+// isArchive判断文件是否为zip或tar归档（包括压缩的tar）。
+// 文件不存在或无法读取时返回false。
+func isArchive(filename string) bool {
+	file, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		file.Close()
+		return false
+	}
+	rc, err := openPayload(file, io.NewSectionReader(file, 0, info.Size()), "", file)
+	if err != nil {
+		file.Close()
+		return false
+	}
+	defer rc.Close()
+	switch rc.(*payloadReader).Reader.(type) {
+	case *zipReader, *entryReader:
+		return true
+	}
+	return false
+}
+
+// decompress按魔数解压r，需要关闭的解压器加入rc。未压缩时直接返回r。
+func decompress(r *bufio.Reader, rc *payloadReader) (io.Reader, error) {
+	magic, _ := r.Peek(len(xzMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %v", err)
+		}
+		rc.closers = append(rc.closers, gz.Close)
+		return gz, nil
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return bzip2.NewReader(r), nil
+	case bytes.HasPrefix(magic, xzMagic):
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create xz reader: %v", err)
+		}
+		return xr, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %v", err)
+		}
+		rc.closers = append(rc.closers, func() error {
+			zr.Close()
+			return nil
+		})
+		return zr, nil
+	}
+	return r, nil
+}
+
+// payloadReader在关闭时按相反顺序关闭解压器和底层文件。
+type payloadReader struct {
+	io.Reader
+	closers []func() error
+}
+
+func (r *payloadReader) Close() error {
+	var err error
+	if c, ok := r.Reader.(io.Closer); ok {
+		err = c.Close()
+	}
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if cerr := r.closers[i](); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// entryReader把归档中匹配的条目依次连接成一个流。条目不以换行结尾时
+// 补充换行，以免与下一个条目的第一行相连。
+type entryReader struct {
+	// next返回下一个匹配的条目，没有更多条目时返回io.EOF
+	next    func() (io.Reader, error)
+	current io.Reader
+	inner   *payloadReader // 当前条目的解压器
+	last    byte           // 当前条目读到的最后一个字节
+	newline bool
+}
+
+func (r *entryReader) Read(p []byte) (int, error) {
+	for len(p) > 0 {
+		if r.newline {
+			r.newline = false
+			p[0] = '\n'
+			return 1, nil
+		}
+		if r.current == nil {
+			entry, err := r.next()
+			if err != nil {
+				return 0, err
+			}
+			// 条目本身也可能是压缩的
+			r.inner = &payloadReader{}
+			r.current, err = decompress(bufio.NewReader(entry), r.inner)
+			if err != nil {
+				return 0, err
+			}
+			r.last = '\n'
+		}
+
+		n, err := r.current.Read(p)
+		if n > 0 {
+			r.last = p[n-1]
+		}
+		if err == io.EOF {
+			r.inner.Close()
+			r.current, r.inner = nil, nil
+			r.newline = r.last != '\n'
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+	return 0, nil
+}
+
+// Close关闭当前条目的解压器
+func (r *entryReader) Close() error {
+	if r.inner == nil {
+		return nil
+	}
+	return r.inner.Close()
+}
+
+// newZipReader返回zip归档中匹配模式的文件内容
+func newZipReader(zr *zip.Reader, pattern string) io.Reader {
+	files := zr.File
+	var open io.ReadCloser
+	return &zipReader{entryReader{next: func() (io.Reader, error) {
+		if open != nil {
+			open.Close()
+			open = nil
+		}
+		for len(files) > 0 {
+			f := files[0]
+			files = files[1:]
+			if f.FileInfo().IsDir() || !matchEntry(pattern, f.Name) {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to open %s: %v", f.Name, err)
+			}
+			open = rc
+			return rc, nil
+		}
+		return nil, io.EOF
+	}}, &open}
+}
+
+// zipReader在关闭时关闭正在读取的条目
+type zipReader struct {
+	entryReader
+	open *io.ReadCloser
+}
+
+func (r *zipReader) Close() error {
+	r.entryReader.Close()
+	if *r.open == nil {
+		return nil
+	}
+	return (*r.open).Close()
+}
+
+// newTarReader返回tar归档中匹配模式的文件内容
+func newTarReader(tr *tar.Reader, pattern string) io.Reader {
+	return &entryReader{next: func() (io.Reader, error) {
+		for {
+			header, err := tr.Next()
+			if err != nil {
+				return nil, err
+			}
+			if header.Typeflag == tar.TypeReg && matchEntry(pattern, header.Name) {
+				return tr, nil
+			}
+		}
+	}}
+}
+
+// matchEntry返回归档条目是否匹配模式，空模式匹配所有条目
+func matchEntry(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, strings.TrimPrefix(name, "./"))
+	return ok
 }
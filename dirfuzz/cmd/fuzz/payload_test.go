@@ -0,0 +1,202 @@
+package fuzz
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// archiveEntry is a file of a test archive.
+type archiveEntry struct {
+	name, content string
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func xzBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := xz.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstdBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipBytes(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		f, err := w.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(e.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarBytes(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, e := range entries {
+		w.WriteHeader(&tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.content)), Typeflag: tar.TypeReg})
+		w.Write([]byte(e.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeBytes writes data to name in dir and returns its path.
+func writeBytes(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenPayloadFileFormats(t *testing.T) {
+	dir := t.TempDir()
+	lines := []byte("admin\r\nlogin\n")
+	entries := []archiveEntry{
+		{"web/a.txt", "admin\nlogin"},
+		{"web/b.txt.gz", string(gzipBytes(t, []byte("backup\n")))},
+		{"README", "readme\n"},
+	}
+	// The extensions are misleading on purpose: formats are found by
+	// their magic bytes.
+	tests := []struct {
+		name string
+		spec string
+		want []string
+	}{
+		{"plain", writeBytes(t, dir, "plain.gz", lines), []string{"admin", "login"}},
+		{"gzip", writeBytes(t, dir, "gzip.txt", gzipBytes(t, lines)), []string{"admin", "login"}},
+		{"xz", writeBytes(t, dir, "xz.txt", xzBytes(t, lines)), []string{"admin", "login"}},
+		{"zstd", writeBytes(t, dir, "zstd.txt", zstdBytes(t, lines)), []string{"admin", "login"}},
+		{"zip", writeBytes(t, dir, "lists.zip", zipBytes(t, entries...)), []string{"admin", "login", "backup", "readme"}},
+		{"zip glob", filepath.Join(dir, "lists.zip") + ":web/*.txt", []string{"admin", "login"}},
+		{"compressed zip entry", filepath.Join(dir, "lists.zip") + ":web/*.gz", []string{"backup"}},
+		{"tar.gz", writeBytes(t, dir, "lists.tgz", gzipBytes(t, tarBytes(t, entries...))), []string{"admin", "login", "backup", "readme"}},
+		{"tar glob", filepath.Join(dir, "lists.tgz") + ":README", []string{"readme"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadPayloadFile(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("read %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenPayloadFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	plain := writeBytes(t, dir, "plain.txt", []byte("admin\n"))
+	archive := writeBytes(t, dir, "lists.zip", zipBytes(t, archiveEntry{"a.txt", "a\n"}))
+	for _, spec := range []string{
+		filepath.Join(dir, "missing.txt"),
+		plain + ":*.txt",
+		archive + ":[",
+	} {
+		if rc, err := OpenPayloadFile(spec); err == nil {
+			rc.Close()
+			t.Errorf("OpenPayloadFile(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestOpenPayloadStreamsZipFromStdinFails(t *testing.T) {
+	_, err := openPayload(bytes.NewReader(zipBytes(t, archiveEntry{"a", "a"})), nil, "", nil)
+	if err == nil {
+		t.Error("zip read from a stream")
+	}
+	rc, err := openPayload(bytes.NewReader(gzipBytes(t, tarBytes(t, archiveEntry{"a", "a"}))), nil, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if data, _ := io.ReadAll(rc); string(data) != "a\n" {
+		t.Errorf("read %q from a tar.gz stream", data)
+	}
+}
+
+func TestParseWordlist(t *testing.T) {
+	dir := t.TempDir()
+	plain := writeBytes(t, dir, "users.txt", []byte("root\n"))
+	archive := writeBytes(t, dir, "lists.zip", zipBytes(t, archiveEntry{"README", "readme\n"}))
+	tarball := writeBytes(t, dir, "lists.tar.gz", gzipBytes(t, tarBytes(t, archiveEntry{"README", "readme\n"})))
+	tests := []struct {
+		spec          string
+		path, keyword string
+	}{
+		{plain, plain, "FUZZ"},
+		{plain + ":USER", plain, "USER"},
+		{plain + ":user", plain + ":user", "FUZZ"},
+		{"-", "-", "FUZZ"},
+		{"-:PASS", "-", "PASS"},
+		{"-:README:FUZZ", "-:README", "FUZZ"},
+		{"range:1-100:ID", "range:1-100", "ID"},
+		{"builtin:common:DIR", "builtin:common", "DIR"},
+		{archive + ":web/*.txt:FUZZ", archive + ":web/*.txt", "FUZZ"},
+		{archive + ":*:USER", archive + ":*", "USER"},
+		// The entry pattern comes before the keyword.
+		{archive + ":README", archive + ":README", "FUZZ"},
+		{tarball + ":README", tarball + ":README", "FUZZ"},
+		{archive + ":README:NAME", archive + ":README", "NAME"},
+	}
+	for _, tt := range tests {
+		got := ParseWordlist(tt.spec)
+		if got.Path != tt.path || got.Keyword != tt.keyword {
+			t.Errorf("ParseWordlist(%q) = %q, %q, want %q, %q", tt.spec, got.Path, got.Keyword, tt.path, tt.keyword)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package fuzz
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// LoadRawRequest reads a raw HTTP request, as copied from Burp, from a file.
+// See ParseRawRequest.
+func LoadRawRequest(filename, scheme string) (*Request, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	r, err := ParseRawRequest(data, scheme)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return r, nil
+}
+
+// ParseRawRequest parses a raw HTTP/1.x request. The request target is
+// either an absolute URL or a path, which is requested with the given scheme
+// from the host of the Host header. Header names keep their case and order.
+// Content-Length and Transfer-Encoding are dropped, as the length is that
+// of the body after substitution. The body is everything after the blank
+// line, as it is.
+func ParseRawRequest(data []byte, scheme string) (*Request, error) {
+	// Leading blank lines are left over from copying, and must not be
+	// taken for the end of the head.
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if len(data) == 0 {
+		return nil, errors.New("empty request")
+	}
+	head, body := data, []byte(nil)
+	if i := bytes.Index(data, []byte("\r\n\r\n")); i >= 0 {
+		head, body = data[:i], data[i+4:]
+	} else if i := bytes.Index(data, []byte("\n\n")); i >= 0 {
+		head, body = data[:i], data[i+2:]
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(head), "\r\n", "\n"), "\n")
+	fields := strings.Fields(lines[0])
+	if len(fields) != 3 || !strings.HasPrefix(fields[2], "HTTP/") {
+		return nil, fmt.Errorf("invalid request line %q (want \"METHOD TARGET HTTP/1.1\")", lines[0])
+	}
+	r := &Request{Method: fields[0], Header: make(http.Header)}
+	target := fields[1]
+
+	var host string
+	for _, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || name == "" || strings.TrimSpace(name) != name {
+			return nil, fmt.Errorf("invalid header line %q", line)
+		}
+		value = strings.TrimSpace(value)
+		if strings.EqualFold(name, "Host") && host == "" {
+			host = value
+		}
+		r.HeaderOrder = append(r.HeaderOrder, name)
+		if strings.EqualFold(name, "Content-Length") || strings.EqualFold(name, "Transfer-Encoding") {
+			continue
+		}
+		r.Header[name] = append(r.Header[name], value)
+	}
+
+	if strings.Contains(target, "://") {
+		r.URL = target
+	} else {
+		if host == "" {
+			return nil, errors.New("no Host header for the request target " + target)
+		}
+		if !strings.HasPrefix(target, "/") {
+			target = "/" + target
+		}
+		r.URL = scheme + "://" + host + target
+	}
+	if _, err := url.Parse(r.URL); err != nil {
+		return nil, fmt.Errorf("invalid request URL: %v", err)
+	}
+
+	if len(body) > 0 {
+		r.Body = body
+	}
+	return r, nil
+}
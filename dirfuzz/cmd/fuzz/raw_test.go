@@ -0,0 +1,141 @@
+package fuzz
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseRawRequest(t *testing.T) {
+	tests := []struct {
+		name, raw, scheme string
+		url               string
+		order             string
+		body              string
+	}{
+		{
+			name:   "Burp copy",
+			raw:    "POST /api/FUZZ?x=1 HTTP/1.1\r\nHost: app.example.org\r\nx-Token: abc\r\nContent-Length: 99\r\nCookie: a=1\r\nCookie: b=2\r\n\r\n{\"id\":\"FUZZ\"}\r\n",
+			scheme: "https",
+			url:    "https://app.example.org/api/FUZZ?x=1",
+			order:  "Host x-Token Content-Length Cookie Cookie",
+			body:   "{\"id\":\"FUZZ\"}\r\n",
+		},
+		{
+			name:   "LF line endings and leading blank lines",
+			raw:    "\n\nGET admin HTTP/1.0\nHOST: h:8080\n\n",
+			scheme: "http",
+			url:    "http://h:8080/admin",
+			order:  "HOST",
+		},
+		{
+			name:   "absolute target",
+			raw:    "GET http://proxy.test/a HTTP/1.1\r\nTransfer-Encoding: chunked\r\nHost: other\r\n\r\nbody",
+			scheme: "https",
+			url:    "http://proxy.test/a",
+			order:  "Transfer-Encoding Host",
+			body:   "body",
+		},
+		{
+			name:   "no body",
+			raw:    "GET / HTTP/1.1\r\nHost: h",
+			scheme: "https",
+			url:    "https://h/",
+			order:  "Host",
+		},
+	}
+	for _, tt := range tests {
+		r, err := ParseRawRequest([]byte(tt.raw), tt.scheme)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if r.URL != tt.url || strings.Join(r.HeaderOrder, " ") != tt.order || string(r.Body) != tt.body {
+			t.Errorf("%s: URL %s, header order %q, body %q", tt.name, r.URL, r.HeaderOrder, r.Body)
+		}
+		for name := range r.Header {
+			if strings.EqualFold(name, "Content-Length") || strings.EqualFold(name, "Transfer-Encoding") {
+				t.Errorf("%s: %s header kept", tt.name, name)
+			}
+		}
+	}
+
+	r, _ := ParseRawRequest([]byte(tests[0].raw), "https")
+	if r.Method != "POST" || r.Header["x-Token"][0] != "abc" || strings.Join(r.Header["Cookie"], ";") != "a=1;b=2" {
+		t.Errorf("method %s, headers %v", r.Method, r.Header)
+	}
+}
+
+func TestParseRawRequestErrors(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"\r\n\r\n",
+		"GET /\r\nHost: h\r\n\r\n",
+		"GET / SPDY/3\r\nHost: h\r\n\r\n",
+		"GET / HTTP/1.1\r\n\r\n",
+		"GET / HTTP/1.1\r\nHost h\r\n\r\n",
+		"GET / HTTP/1.1\r\n Host: h\r\n\r\n",
+		"GET / HTTP/1.1\r\nHost: h:port\r\n\r\n",
+	} {
+		if r, err := ParseRawRequest([]byte(raw), "https"); err == nil {
+			t.Errorf("ParseRawRequest(%q) = %+v", raw, r)
+		}
+	}
+}
+
+func TestLoadRawRequest(t *testing.T) {
+	path := writeFile(t, "request.txt", "GET /FUZZ?q=%41 HTTP/1.1\r\nHost: app.example.org\r\n\r\n")
+	tests := []struct {
+		options Options
+		url     string
+	}{
+		{Options{RequestFile: path}, "https://app.example.org/FUZZ?q=%41"},
+		{Options{RequestFile: path, RequestScheme: "http"}, "http://app.example.org/FUZZ?q=%41"},
+		// The target only sets where the request is sent.
+		{Options{RequestFile: path, TargetURL: "http://127.0.0.1:8080/ignored"}, "http://127.0.0.1:8080/FUZZ?q=%41"},
+	}
+	for _, tt := range tests {
+		r, err := tt.options.loadRawRequest(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.URL != tt.url || r.Header.Get("Host") != "app.example.org" {
+			t.Errorf("%+v: URL %s, Host %s", tt.options, r.URL, r.Header.Get("Host"))
+		}
+	}
+
+	broken := writeFile(t, "broken.txt", "not a request")
+	if _, err := LoadRawRequest(broken, "https"); err == nil || !strings.Contains(err.Error(), broken) {
+		t.Errorf("error %v, want the file name", err)
+	}
+	if _, err := LoadRawRequest(broken+".missing", "https"); err == nil {
+		t.Error("missing file loaded")
+	}
+}
+
+func TestScanRawRequest(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		want := `{"user":"admin"}`
+		if r.Host == "app.example.org" && r.URL.Path == "/api/admin" && r.Header.Get("X-Token") == "t" &&
+			r.Header.Get("X-Extra") == "1" && string(body) == want && r.ContentLength == int64(len(want)) {
+			w.Write([]byte("ok"))
+			return
+		}
+		http.NotFound(w, r)
+	}
+	request := writeFile(t, "request.txt", "POST /api/FUZZ HTTP/1.1\r\nHost: app.example.org\r\nX-Token: t\r\n"+
+		"Content-Type: application/json\r\nContent-Length: 2\r\n\r\n{\"user\":\"FUZZ\"}")
+	wordlist := writeFile(t, "words.txt", "guest\nadmin\n")
+	got := scan(t, handler, Options{
+		TargetURL:    "SERVER",
+		RequestFile:  request,
+		WordlistFile: wordlist,
+		Headers:      []string{"X-Extra: 1"},
+		NoRecursion:  true,
+	})
+	if strings.Join(got, " ") != "/api/admin" {
+		t.Errorf("found %v", got)
+	}
+}
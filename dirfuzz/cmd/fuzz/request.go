@@ -1,30 +1,194 @@
-package main
+package fuzz
 
 import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 )
 
+// Keyword is the placeholder replaced by each payload.
+const Keyword = "FUZZ"
+
 // Request represents an HTTP request.
 type Request struct {
 	Method string
 	URL    string
 	Body   []byte
 	Header http.Header
+	// HeaderOrder lists the header names in the order and case they are
+	// sent, as read from a raw request. Without it, Go's order is used.
+	HeaderOrder []string
 }
 
-// Do sends the HTTP request and returns the response.
-func (r *Request) Do() (*http.Response, error) {
-	req, err := http.NewRequest(r.Method, r.URL, bytes.NewReader(r.Body))
+// HasKeyword reports whether the keyword appears anywhere in the request:
+// method, URL, header names and values, or body.
+func (r *Request) HasKeyword(keyword string) bool {
+	if strings.Contains(r.Method, keyword) || strings.Contains(r.URL, keyword) ||
+		bytes.Contains(r.Body, []byte(keyword)) {
+		return true
+	}
+	for name, values := range r.Header {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+		for _, v := range values {
+			if strings.Contains(v, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Substitute returns a copy of the request with every keyword replaced by
+// its value. Longer keywords are replaced first, so FUZZ2 is not taken for
+// FUZZ followed by a 2.
+func (r *Request) Substitute(values map[string]string) *Request {
+	keywords := make([]string, 0, len(values))
+	for keyword := range values {
+		keywords = append(keywords, keyword)
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if len(keywords[i]) != len(keywords[j]) {
+			return len(keywords[i]) > len(keywords[j])
+		}
+		return keywords[i] < keywords[j]
+	})
+	pairs := make([]string, 0, len(values)*2)
+	for _, keyword := range keywords {
+		pairs = append(pairs, keyword, values[keyword])
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	header := make(http.Header, len(r.Header))
+	for name, vs := range r.Header {
+		name = replacer.Replace(name)
+		for _, v := range vs {
+			header[name] = append(header[name], replacer.Replace(v))
+		}
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body = []byte(replacer.Replace(string(r.Body)))
+	}
+
+	var order []string
+	for _, name := range r.HeaderOrder {
+		order = append(order, replacer.Replace(name))
+	}
+
+	return &Request{
+		Method:      replacer.Replace(r.Method),
+		URL:         replacer.Replace(r.URL),
+		Body:        body,
+		Header:      header,
+		HeaderOrder: order,
+	}
+}
+
+// HTTPRequest creates the request to send. A Host header in the request
+// overrides the host of the URL, so virtual hosts can be fuzzed. The
+// Content-Length is always that of the body, whatever the headers say. The
+// header order of a raw request is kept by the scanner where its transport
+// allows, see orderHeaders.
+func (r *Request) HTTPRequest() (*http.Request, error) {
+	u, err := requestURL(r.URL)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %v", err)
+	}
+	req, err := http.NewRequest(r.Method, "", bytes.NewReader(r.Body))
 	if err != nil {
 		return nil, fmt.Errorf("could not create request: %v", err)
 	}
+	req.URL, req.Host = u, u.Host
+
+	req.Header = r.Header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	for name, values := range req.Header {
+		switch {
+		case strings.EqualFold(name, "Host"):
+			if len(values) > 0 {
+				req.Host = values[0]
+			}
+			delete(req.Header, name)
+		case strings.EqualFold(name, "Content-Length"), strings.EqualFold(name, "Transfer-Encoding"):
+			delete(req.Header, name)
+		}
+	}
+	if len(r.HeaderOrder) > 0 {
+		// The names of a raw request are canonicalized so that the
+		// transport sees headers such as User-Agent. wireConn writes them
+		// as given in the order.
+		header := make(http.Header, len(req.Header))
+		for name, values := range req.Header {
+			for _, value := range values {
+				header.Add(name, value)
+			}
+		}
+		req.Header = header
+	}
+	return req, nil
+}
+
+// requestURL parses the URL of a request. Encoded payloads may hold escapes
+// that url.Parse rejects, such as the %uXXXX of the unicode encoder, or a #
+// that would start a fragment, as in the &#x..; of htmlall. The path and
+// query of such a URL are sent as written, through URL.Opaque.
+func requestURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err == nil && !strings.Contains(raw, "#") {
+		return u, nil
+	}
+	scheme, rest, ok := strings.Cut(raw, "://")
+	if !ok {
+		if err == nil {
+			err = fmt.Errorf("invalid URL %q", raw)
+		}
+		return nil, err
+	}
+	i := strings.IndexAny(rest, "/?#")
+	if i < 0 {
+		i = len(rest)
+	}
+	u, err = url.Parse(scheme + "://" + rest[:i])
+	if err != nil {
+		return nil, err
+	}
+	opaque := rest[i:]
+	if !strings.HasPrefix(opaque, "/") {
+		opaque = "/" + opaque
+	}
+	u.Opaque = opaque
+	// The path is kept for the checks made on the response, such as
+	// isDirectory; the request line only uses Opaque.
+	u.Path, _, _ = strings.Cut(opaque, "?")
+	return u, nil
+}
+
+// orderHeaders asks the connection to write the headers of req, created by
+// HTTPRequest, in the order and case of the raw request. Only connections
+// wrapped by wireConn understand it, and the body must have a length.
+func (r *Request) orderHeaders(req *http.Request) {
+	if len(r.HeaderOrder) > 0 && req.ContentLength >= 0 {
+		req.Header[headerOrderHeader] = []string{strings.Join(r.HeaderOrder, ",")}
+	}
+}
+
+// Do sends the HTTP request with DefaultClient and returns the response.
+func (r *Request) Do() (*http.Response, error) {
+	req, err := r.HTTPRequest()
+	if err != nil {
+		return nil, err
+	}
 
-	req.Header = r.Header
-	client := http.Client{}
-	return client.Do(req)
+	return DefaultClient.Do(req)
 }
 
 // Send sends the HTTP request and returns the response body.
@@ -0,0 +1,164 @@
+package fuzz
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSubstitute(t *testing.T) {
+	template := &Request{
+		Method: "FUZZ",
+		URL:    "http://h/FUZZ?user=USER",
+		Header: http.Header{"X-FUZZ": {"USER-FUZZ"}, "Cookie": {"id=USER"}},
+		Body:   []byte(`{"user":"USER","path":"FUZZ"}`),
+	}
+	r := template.Substitute(map[string]string{Keyword: "admin", "USER": "root"})
+	if r.Method != "admin" || r.URL != "http://h/admin?user=root" {
+		t.Errorf("substituted %s %s", r.Method, r.URL)
+	}
+	// Header names are kept as written.
+	if got := r.Header["X-admin"]; len(got) != 1 || got[0] != "root-admin" || r.Header.Get("Cookie") != "id=root" {
+		t.Errorf("substituted headers %v", r.Header)
+	}
+	if string(r.Body) != `{"user":"root","path":"admin"}` {
+		t.Errorf("substituted body %s", r.Body)
+	}
+	// The template is left as it was.
+	if template.URL != "http://h/FUZZ?user=USER" || template.Header["X-FUZZ"][0] != "USER-FUZZ" {
+		t.Errorf("template changed to %+v", template)
+	}
+	// A value holding another keyword is not substituted again.
+	r = template.Substitute(map[string]string{Keyword: "USER", "USER": "root"})
+	if r.URL != "http://h/USER?user=root" {
+		t.Errorf("substituted URL %s", r.URL)
+	}
+	// A keyword that starts with another is replaced as a whole, whatever
+	// the map order.
+	prefixed := &Request{URL: "x/FUZZ2/FUZZ/USERNAME/USER"}
+	for i := 0; i < 20; i++ {
+		r = prefixed.Substitute(map[string]string{Keyword: "a", "FUZZ2": "b", "USER": "c", "USERNAME": "d"})
+		if r.URL != "x/b/a/d/c" {
+			t.Fatalf("substituted URL %s", r.URL)
+		}
+	}
+}
+
+func TestHasKeyword(t *testing.T) {
+	tests := []struct {
+		r    *Request
+		want bool
+	}{
+		{&Request{Method: "GET", URL: "http://h/FUZZ"}, true},
+		{&Request{Method: "GET", URL: "http://h/", Header: http.Header{"Host": {"FUZZ.example.org"}}}, true},
+		{&Request{Method: "GET", URL: "http://h/", Header: http.Header{"X-FUZZ": {"1"}}}, true},
+		{&Request{Method: "POST", URL: "http://h/", Body: []byte("q=FUZZ")}, true},
+		{&Request{Method: "GET", URL: "http://h/fuzz"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.r.HasKeyword(Keyword); got != tt.want {
+			t.Errorf("HasKeyword(%+v) = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPRequest(t *testing.T) {
+	r := &Request{
+		Method: "POST",
+		URL:    "http://127.0.0.1/login",
+		Header: http.Header{"Host": {"admin.example.org"}, "Content-Length": {"999"}, "X-Token": {"t"}},
+		Body:   []byte("user=a"),
+	}
+	req, err := r.HTTPRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Host != "admin.example.org" || req.URL.Host != "127.0.0.1" {
+		t.Errorf("host %s, URL host %s", req.Host, req.URL.Host)
+	}
+	if req.ContentLength != 6 || req.Header.Get("Content-Length") != "" || req.Header.Get("Host") != "" {
+		t.Errorf("content length %d, headers %v", req.ContentLength, req.Header)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != "user=a" {
+		t.Errorf("body %q", body)
+	}
+	if r.Header.Get("Host") == "" {
+		t.Error("HTTPRequest changed the template headers")
+	}
+
+	// Encoded payloads are sent as written, even where url.Parse would
+	// reject them or take them for a fragment.
+	tests := []struct {
+		url, uri, path string
+	}{
+		{"http://h/a%20b?x=1", "/a%20b?x=1", "/a b"},
+		{"http://h/%zz", "/%zz", "/%zz"},
+		{"http://h/a%u0041?x=%u0042", "/a%u0041?x=%u0042", "/a%u0041"},
+		{"http://h/admin&#x2e;php#x", "/admin&#x2e;php#x", "/admin&#x2e;php#x"},
+		{"http://h?q=&#x41;", "/?q=&#x41;", "/"},
+	}
+	for _, tt := range tests {
+		req, err := (&Request{Method: "GET", URL: tt.url}).HTTPRequest()
+		if err != nil {
+			t.Errorf("%s: %v", tt.url, err)
+			continue
+		}
+		if req.URL.RequestURI() != tt.uri || req.URL.Path != tt.path || req.Host != "h" {
+			t.Errorf("%s: request URI %s, path %s, host %s", tt.url, req.URL.RequestURI(), req.URL.Path, req.Host)
+		}
+	}
+	for _, u := range []string{"http://h:port/%zz", "/%zz", "http://h:port/"} {
+		if _, err := (&Request{Method: "GET", URL: u}).HTTPRequest(); err == nil {
+			t.Errorf("invalid URL %s accepted", u)
+		}
+	}
+}
+
+func TestNewTemplateKeywords(t *testing.T) {
+	lists := []Wordlist{{Keyword: Keyword}, {Keyword: "USER"}}
+	o := &Options{TargetURL: "http://h/", Headers: []string{"X-User: USER"}, Data: "a=1"}
+	r, err := o.newTemplate(lists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.URL != "http://h/FUZZ" || r.Header.Get("X-User") != "USER" || string(r.Body) != "a=1" || r.Method != http.MethodGet {
+		t.Errorf("template %+v", r)
+	}
+
+	// Only FUZZ is appended to the URL.
+	o = &Options{TargetURL: "http://h/FUZZ"}
+	if _, err := o.newTemplate(lists); err == nil || !strings.Contains(err.Error(), "USER") {
+		t.Errorf("newTemplate error = %v, want USER missing", err)
+	}
+	for _, header := range []string{"X-User", ": value"} {
+		o = &Options{TargetURL: "http://h/", Headers: []string{header}}
+		if _, err := o.newTemplate(nil); err == nil {
+			t.Errorf("header %q accepted", header)
+		}
+	}
+}
+
+func TestScanKeywordInHeaderAndBody(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Host == "admin.example.org" && r.Header.Get("X-Role") == "root" && string(body) == "role=root" {
+			w.Write([]byte("welcome"))
+			return
+		}
+		http.NotFound(w, r)
+	}
+	hosts := writeFile(t, "hosts.txt", "www\nadmin\n")
+	roles := writeFile(t, "roles.txt", "guest\nroot\n")
+	got := scan(t, handler, Options{
+		TargetURL:   "SERVER/",
+		Method:      http.MethodPost,
+		Headers:     []string{"Host: FUZZ.example.org", "X-Role: ROLE"},
+		Data:        "role=ROLE",
+		Wordlists:   []string{hosts, roles + ":ROLE"},
+		NoRecursion: true,
+	})
+	if strings.Join(got, " ") != "/" {
+		t.Errorf("found %v", got)
+	}
+}
@@ -1,138 +1,763 @@
 package fuzz
 
 import (
-    "bufio"
-    "bytes"
-    "fmt"
-    "io/ioutil"
-    "net/http"
-    "net/url"
-    "os"
-    "path/filepath"
-    "strings"
-
-    "github.com/PuerkitoBio/goquery"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/your-username/dirfuzz/output"
 )
 
 // Scanner is responsible for generating fuzzing requests.
 type Scanner struct {
-    baseURL      string
-    inputDir     string
-    cookieHeader string
-    filters      []*Filter
-    client       *http.Client
-    // debugFunc is a function that will be called to print debug messages.
-    debugFunc func(msg string)
-}
-
-// NewScanner returns a new Scanner instance.
-func NewScanner(baseURL string, inputDir string, cookieHeader string, filters []*Filter, client *http.Client, debugFunc func(msg string)) *Scanner {
-    return &Scanner{
-        baseURL:      baseURL,
-        inputDir:     inputDir,
-        cookieHeader: cookieHeader,
-        filters:      filters,
-        client:       client,
-        debugFunc:    debugFunc,
-    }
-}
-
-// Run executes the scanner.
-func (s *Scanner) Run() error {
-    err := filepath.Walk(s.inputDir, func(path string, info os.FileInfo, err error) error {
-        if err != nil {
-            return err
-        }
-        if !info.Mode().IsRegular() {
-            return nil
-        }
-        if filepath.Ext(path) != ".txt" {
-            return nil
-        }
-
-        // Open file
-        f, err := os.Open(path)
-        if err != nil {
-            return err
-        }
-        defer f.Close()
-
-        // Read file contents
-        scanner := bufio.NewScanner(f)
-        for scanner.Scan() {
-            // Trim leading/trailing spaces
-            line := strings.TrimSpace(scanner.Text())
-
-            // Skip empty lines
-            if line == "" {
-                continue
-            }
-
-            // Skip comment lines
-            if strings.HasPrefix(line, "#") {
-                continue
-            }
-
-            // Make request
-            err := s.makeRequest(line)
-            if err != nil {
-                s.debugFunc(fmt.Sprintf("[ERROR] %v", err))
-            }
-        }
-
-        if err := scanner.Err(); err != nil {
-            return err
-        }
-
-        return nil
-    })
-
-    return err
-}
-
-// makeRequest sends an HTTP request with the given payload.
-func (s *Scanner) makeRequest(payload string) error {
-	// Apply filters to payload
-	for _, filter := range s.filters {
-		if !filter.Match(payload) {
-			return nil
+	baseURL   string
+	template  *Request
+	recursive bool
+	wordlists []Wordlist
+	rules     Mutations
+	encoders  map[string]Encoders
+	backups   BackupTemplates
+	harvester *Harvester
+	session   *session
+	filter    *Filter
+	client    *http.Client
+	// replayClient resends hits through the replay proxy, if any.
+	replayClient *http.Client
+	replays      sync.WaitGroup
+	options      Options
+	extensions   []string
+	summary      *output.Summary
+	output       output.Output
+	latency      latencyBaseline
+	// debugFunc is a function that will be called to print debug messages.
+	debugFunc func(msg string)
+}
+
+// target is a base URL the wordlist is appended to, or the request template
+// if the keyword is somewhere else.
+type target struct {
+	url      string
+	template *Request
+	depth    int
+	// calibration holds the soft-404 fingerprints of the target.
+	calibration *calibration
+}
+
+// outcome is the result of a single request before it is reported.
+type outcome struct {
+	target  target
+	payload payload
+	request *Request
+	result  output.Result
+	elapsed time.Duration
+	// skipped is set when the payload was filtered out before sending.
+	skipped bool
+	hit     bool
+	// directory is set when the response looks like a directory, whether
+	// or not the scan recurses into it.
+	directory bool
+	err       error
+}
+
+// NewScanner returns a new Scanner instance. It validates the options and
+// loads the files they name, such as the request template, rule files and
+// backup templates.
+func NewScanner(options Options) (*Scanner, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	wordlists := options.wordlists()
+	template, err := options.newTemplate(wordlists)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := options.newFilter()
+	if err != nil {
+		return nil, err
+	}
+	rules, err := options.newRules()
+	if err != nil {
+		return nil, err
+	}
+	encoders, err := options.newEncoders(wordlists)
+	if err != nil {
+		return nil, err
+	}
+	backups, err := options.newBackupTemplates()
+	if err != nil {
+		return nil, err
+	}
+	session, err := newSession(options)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Recursion only makes sense when the keyword is the last path segment.
+	baseURL := template.URL
+	recursive := false
+	if u, err := url.Parse(template.URL); err == nil && strings.HasSuffix(u.Path, "/"+Keyword) {
+		baseURL, recursive = directoryURL(u).String(), true
+	}
+
+	var harvester *Harvester
+	if options.Harvest || options.HarvestOutput != "" {
+		harvester = NewHarvester(options.HarvestMinLength)
+	}
+	var jar http.CookieJar
+	if options.CookieJar || session != nil {
+		jar, _ = cookiejar.New(nil)
+	}
+
+	s := &Scanner{
+		baseURL:   baseURL,
+		template:  template,
+		recursive: recursive,
+		wordlists: wordlists,
+		rules:     rules,
+		encoders:  encoders,
+		backups:   backups,
+		harvester: harvester,
+		session:   session,
+		filter:    filter,
+		client: &http.Client{
+			Transport: newClientTransport(options, tlsConfig),
+			// Redirects are reported, not followed, so that directories
+			// can be recognized by their trailing slash redirect.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+			Jar: jar,
+		},
+		replayClient: newReplayClient(options, tlsConfig),
+		options:      options,
+		extensions:   parseExtensions(options.Extensions),
+		summary:      output.NewSummary(),
+		debugFunc: func(msg string) {
+			fmt.Fprintln(os.Stderr, msg)
+		},
+	}
+	if session != nil {
+		session.send = func(r *Request) (*exchange, error) {
+			req, err := s.httpRequest(r)
+			if err != nil {
+				return &exchange{}, err
+			}
+			return s.send(req)
 		}
 	}
+	return s, nil
+}
+
+// SetOutput sets the output every result is written to as it is found.
+func (s *Scanner) SetOutput(out output.Output) {
+	s.output = out
+}
 
-	// Prepare request
-	req, err := http.NewRequest(s.method, s.url, bytes.NewBuffer([]byte(payload)))
+// Summary returns the statistics of the scan.
+func (s *Scanner) Summary() output.Summary {
+	return *s.summary
+}
+
+// Scan runs the wordlist against the target and, unless recursion is
+// disabled, against every directory discovered below it.
+func (s *Scanner) Scan() ([]output.Result, error) {
+	s.summary.Start()
+	defer s.summary.Finish()
+	defer s.replays.Wait()
+
+	if err := openWordlists(s.wordlists, s.options.Dedupe, s.rules); err != nil {
+		return nil, err
+	}
+	defer closeWordlists(s.wordlists)
+
+	if s.session != nil {
+		if err := s.session.login(); err != nil {
+			return nil, fmt.Errorf("could not log in: %v", err)
+		}
+		s.debugFunc("[INFO] Logged in")
+	}
+
+	jobs, err := countJobs(s.options.Mode, s.wordlists, s.options.MaxRequests > 0)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if jobs < 0 {
+		s.debugFunc("[INFO] Unknown number of requests per base, the wordlist is read from stdin")
+	} else {
+		jobs *= s.expansionFactor()
+		s.debugFunc(fmt.Sprintf("[INFO] %d requests per base", jobs))
+		if s.options.MaxRequests > 0 && jobs > s.options.MaxRequests {
+			return nil, fmt.Errorf("an estimated %d requests per base exceed the limit of %d", jobs, s.options.MaxRequests)
+		}
 	}
 
-	// Set request headers
-	for k, v := range s.headers {
-		req.Header.Set(k, v)
+	var results []output.Result
+	queue := []target{{url: s.baseURL, template: s.template}}
+	seen := map[string]bool{s.baseURL: true}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		s.summary.Depth(t.depth).Bases++
+		s.debugFunc(fmt.Sprintf("[INFO] Scanning %s (depth %d)", t.url, t.depth))
+		if !s.options.NoCalibration {
+			t.calibration = s.calibrate(t)
+			if eta := t.calibration.estimate(jobs, s.options.Threads).Round(time.Second); eta > 0 {
+				s.debugFunc(fmt.Sprintf("[INFO] Estimated time for this base: %v", eta))
+			}
+		}
+
+		var probes []payload
+		probed := make(map[string]bool)
+		collect := func(o outcome) {
+			if o.skipped {
+				return
+			}
+			s.summary.Record(t.depth, o.elapsed, o.err)
+			if o.err != nil {
+				s.debugFunc(fmt.Sprintf("[ERROR] %v", o.err))
+				return
+			}
+			if s.options.Anomaly {
+				if s.latency.isAnomaly(o.elapsed, s.options.AnomalyDeviations) {
+					o.result.Anomaly = true
+					s.summary.Anomalies++
+				} else {
+					s.latency.add(o.elapsed)
+				}
+			}
+			if !o.hit && !o.result.Anomaly {
+				return
+			}
+			s.report(o)
+			results = append(results, o.result)
+
+			if !o.hit {
+				return
+			}
+			s.replay(o.request)
+			for _, p := range s.backupProbes(o) {
+				if !probed[p.values[Keyword]] {
+					probed[p.values[Keyword]] = true
+					probes = append(probes, p)
+				}
+			}
+			if next, ok := s.recurseTarget(o); ok && !seen[next.url] {
+				seen[next.url] = true
+				queue = append(queue, next)
+			}
+		}
+
+		err := s.scanBase(t, s.produceWordlists, collect)
+		if err == nil && s.options.Harvest {
+			err = s.scanHarvested(t, collect)
+		}
+		for err == nil && len(probes) > 0 {
+			batch := probes
+			probes = nil
+			s.debugFunc(fmt.Sprintf("[INFO] Probing %d backup variants at %s", len(batch), t.url))
+			err = s.scanBase(t, func(payloads chan<- payload) error {
+				for _, p := range batch {
+					payloads <- p
+				}
+				return nil
+			}, collect)
+		}
+		if err != nil {
+			return results, err
+		}
 	}
 
-	// Send request
-	resp, err := s.client.Do(req)
+	if s.options.HarvestOutput != "" {
+		if err := s.harvester.Save(s.options.HarvestOutput); err != nil {
+			return results, fmt.Errorf("could not save harvested words: %v", err)
+		}
+		s.debugFunc(fmt.Sprintf("[INFO] Saved %d harvested words to %s", s.harvester.Len(), s.options.HarvestOutput))
+	}
+
+	return results, nil
+}
+
+// expansionFactor returns how many payloads the extensions make of each
+// FUZZ value, ignoring entries that are not expanded.
+func (s *Scanner) expansionFactor() int64 {
+	if hasKeyword(s.wordlists, Keyword) {
+		return int64(1 + len(s.extensions))
+	}
+	return 1
+}
+
+// scanBase runs the payloads of produce against a single target. The
+// producer feeds a pool of workers, and every outcome is handed to collect on
+// the calling goroutine, so collect needs no locking.
+func (s *Scanner) scanBase(t target, produce func(payloads chan<- payload) error, collect func(o outcome)) error {
+	payloads := make(chan payload, s.options.Threads)
+	outcomes := make(chan outcome, s.options.Threads)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.options.Threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range payloads {
+				outcomes <- s.makeRequest(t, p)
+			}
+		}()
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		defer close(payloads)
+		errc <- produce(payloads)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	for o := range outcomes {
+		collect(o)
+	}
+
+	return <-errc
+}
+
+// produceWordlists sends the combined and expanded wordlist entries.
+func (s *Scanner) produceWordlists(payloads chan<- payload) error {
+	return combineWordlists(s.options.Mode, s.wordlists, func(values map[string]string) error {
+		for _, p := range expandPayloads(values, s.extensions, s.options.ExtensionsNoDot) {
+			payloads <- p
+		}
+		return nil
+	})
+}
+
+// scanHarvested tries the words harvested so far that are not in the FUZZ
+// wordlist, most frequent first, as values of FUZZ. The other keywords keep
+// the first entry of their wordlist. The hits of each round may yield new
+// words, so rounds are repeated until none are left or HarvestLimit words
+// were tried.
+func (s *Scanner) scanHarvested(t target, collect func(o outcome)) error {
+	var fuzzList *Dictionary
+	for _, list := range s.wordlists {
+		if list.Keyword == Keyword {
+			fuzzList = list.dictionary
+		}
+	}
+	defaults, err := firstEntries(s.wordlists)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	body, err := ioutil.ReadAll(resp.Body)
+	tried := make(map[string]bool)
+	for {
+		var words []string
+		for _, hw := range s.harvester.Words() {
+			if s.options.HarvestLimit > 0 && len(tried)+len(words) >= s.options.HarvestLimit {
+				break
+			}
+			if !tried[hw.Word] && !fuzzList.Has(hw.Word) {
+				words = append(words, hw.Word)
+			}
+		}
+		if len(words) == 0 {
+			return nil
+		}
+		s.debugFunc(fmt.Sprintf("[INFO] Trying %d harvested words at %s", len(words), t.url))
+
+		for _, word := range words {
+			tried[word] = true
+		}
+		err := s.scanBase(t, func(payloads chan<- payload) error {
+			values := copyValues(defaults)
+			for _, word := range words {
+				values[Keyword] = word
+				for _, p := range expandPayloads(values, s.extensions, s.options.ExtensionsNoDot) {
+					payloads <- p
+				}
+			}
+			return nil
+		}, collect)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// backupProbes returns the backup variants to probe for a hit. Variants
+// are not probed for hits that are variants themselves. {dir} templates
+// apply to hits that look like directories, even when the scan does not
+// recurse.
+func (s *Scanner) backupProbes(o outcome) []payload {
+	if s.backups == nil || o.payload.variant != "" {
+		return nil
+	}
+	value, ok := o.payload.values[Keyword]
+	if !ok {
+		return nil
+	}
+	var probes []payload
+	for _, v := range s.backups.Variants(value, o.directory) {
+		p := payload{values: copyValues(o.payload.values), variant: v.variant}
+		p.values[Keyword] = v.value
+		probes = append(probes, p)
+	}
+	return probes
+}
+
+// report prints a hit or slow response, writes it to the output and counts
+// it in the summary.
+func (s *Scanner) report(o outcome) {
+	if o.hit {
+		depth := s.summary.Depth(o.target.depth)
+		depth.Hits++
+		if o.result.Directory {
+			depth.Directories++
+		}
+	}
+	marker := ""
+	if o.result.Anomaly {
+		marker = " [SLOW]"
+	}
+	if o.result.Variant != "" {
+		marker += " [" + o.result.Variant + "]"
+	}
+	fmt.Fprintf(os.Stderr, "[%d] %8d %6dW %5dL %8v %s%s\n", o.result.StatusCode, o.result.ContentLength,
+		o.result.Words, o.result.Lines, o.result.Duration.Truncate(time.Millisecond), o.result.URL, marker)
+
+	if s.output != nil {
+		if err := s.output.Write(o.result); err != nil {
+			s.debugFunc(fmt.Sprintf("[ERROR] Could not write result: %v", err))
+		}
+	}
+}
+
+// recurseTarget returns the base to queue for a hit, if it is a directory
+// that should be scanned.
+func (s *Scanner) recurseTarget(o outcome) (target, bool) {
+	if s.options.NoRecursion || !s.recursive || !o.result.Directory {
+		return target{}, false
+	}
+	depth := o.target.depth + 1
+	if s.options.MaxDepth > 0 && depth > s.options.MaxDepth {
+		return target{}, false
+	}
+
+	u, err := url.Parse(o.result.URL)
 	if err != nil {
-		return err
+		return target{}, false
+	}
+	dir := appendPath(u, "")
+	dirURL := directoryURL(dir).String()
+	for _, ignored := range s.options.IgnoreDir {
+		if isIgnoredDir(dir.Path, ignored) {
+			s.debugFunc(fmt.Sprintf("[INFO] Not recursing into ignored directory %s", dirURL))
+			return target{}, false
+		}
 	}
 
-	// Parse response body with goquery
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	// The new template keeps the query and fragment of the template.
+	templateURL, err := url.Parse(s.template.URL)
 	if err != nil {
-		return err
+		return target{}, false
+	}
+	templateURL.Path, templateURL.RawPath = dir.Path+Keyword, ""
+	if dir.RawPath != "" {
+		templateURL.RawPath = dir.RawPath + Keyword
+	}
+	template := *s.template
+	template.URL = templateURL.String()
+	return target{url: dirURL, template: &template, depth: depth}, true
+}
+
+// appendPath returns a copy of the URL with elem appended to its path as a
+// new segment, before the query and fragment.
+func appendPath(u *url.URL, elem string) *url.URL {
+	v := *u
+	if !strings.HasSuffix(v.Path, "/") {
+		v.Path += "/"
+		if v.RawPath != "" {
+			v.RawPath += "/"
+		}
+	}
+	v.Path += elem
+	if v.RawPath != "" {
+		v.RawPath += elem
+	}
+	return &v
+}
+
+// directoryURL returns the URL of the directory the last path segment of
+// the URL is in, without the query and fragment.
+func directoryURL(u *url.URL) *url.URL {
+	v := *u
+	v.Path = v.Path[:strings.LastIndex(v.Path, "/")+1]
+	if i := strings.LastIndex(v.RawPath, "/"); i >= 0 {
+		v.RawPath = v.RawPath[:i+1]
+	}
+	v.RawQuery, v.ForceQuery, v.Fragment, v.RawFragment = "", false, "", ""
+	return &v
+}
+
+// isIgnoredDir reports whether the directory path ends with the ignored
+// directory, e.g. "/app/backup/" is ignored by "/backup/" and "backup".
+func isIgnoredDir(path, dir string) bool {
+	dir = strings.Trim(dir, "/")
+	if dir == "" {
+		return false
+	}
+	return strings.HasSuffix(path, "/"+dir+"/")
+}
+
+// makeRequest substitutes the payload into the target's template and sends
+// the request.
+func (s *Scanner) makeRequest(t target, p payload) outcome {
+	o := outcome{target: t, payload: p}
+
+	// Apply filters to payload
+	for _, value := range p.values {
+		if !s.filter.Match(value) {
+			o.skipped = true
+			return o
+		}
+	}
+
+	values := p.values
+	if s.recursive {
+		values = copyValues(p.values)
+		values[Keyword] = strings.TrimPrefix(values[Keyword], "/")
+	}
+	p.encoded = s.encode(values)
+	req := t.template.Substitute(p.encoded)
+	o.request = req
+	x, err := s.fetch(req)
+	o.elapsed = x.elapsed
+	if err != nil {
+		o.err = err
+		return o
+	}
+
+	words, lines := CountWords(x.body), CountLines(x.body)
+	o.directory = isDirectory(x.req.URL, x.resp, x.body)
+	subject, sans := peerCertificate(x.resp.TLS)
+	o.hit = s.filter.FilterResponse(&Response{
+		Payload:    s.describe(p),
+		StatusCode: x.resp.StatusCode,
+		Size:       int64(len(x.body)),
+		Words:      words,
+		Lines:      lines,
+		Header:     x.resp.Header,
+		Body:       x.body,
+		TTFB:       x.ttfb,
+		Duration:   x.elapsed,
+	}) && !t.calibration.matches(p, x.resp, x.body)
+	o.result = output.Result{
+		Method:        x.req.Method,
+		URL:           req.URL,
+		Payload:       s.describe(p),
+		Encoded:       s.describeEncoded(p),
+		Extension:     p.extension,
+		Variant:       p.variant,
+		StatusCode:    x.resp.StatusCode,
+		Headers:       x.resp.Header,
+		ContentLength: int64(len(x.body)),
+		Words:         words,
+		Lines:         lines,
+		TTFB:          x.ttfb,
+		Duration:      x.elapsed,
+		Depth:         t.depth,
+		Directory:     s.recursive && o.directory,
+		CertSubject:   subject,
+		CertSANs:      sans,
+	}
+	if o.hit && s.harvester != nil {
+		s.harvester.Harvest(x.req.URL, x.resp.Header, x.body)
+	}
+	return o
+}
+
+// describe returns the payload as reported: the value of a single wordlist,
+// or KEYWORD=value pairs in wordlist order.
+func (s *Scanner) describe(p payload) string {
+	if len(s.wordlists) == 1 {
+		return p.values[s.wordlists[0].Keyword]
+	}
+	pairs := make([]string, 0, len(s.wordlists))
+	for _, list := range s.wordlists {
+		pairs = append(pairs, list.Keyword+"="+p.values[list.Keyword])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// describeEncoded returns the encoded payload as describe does, or "" if
+// no encoder chain applies.
+func (s *Scanner) describeEncoded(p payload) string {
+	if len(s.encoders) == 0 {
+		return ""
+	}
+	return s.describe(payload{values: p.encoded})
+}
+
+// encode passes the values through the encoder chains of their keywords.
+// Without chains the values are returned as they are.
+func (s *Scanner) encode(values map[string]string) map[string]string {
+	if len(s.encoders) == 0 {
+		return values
+	}
+	encoded := copyValues(values)
+	for keyword, e := range s.encoders {
+		if value, ok := encoded[keyword]; ok {
+			encoded[keyword] = e.Encode(value)
+		}
+	}
+	return encoded
+}
+
+// exchange is a sent request with its response.
+type exchange struct {
+	req  *http.Request
+	resp *http.Response
+	body []byte
+	// ttfb is the time until the first response byte, elapsed the time
+	// until the whole body was read.
+	ttfb    time.Duration
+	elapsed time.Duration
+	// tlsState is the TLS state of a wrapped connection.
+	tlsState *tls.ConnectionState
+}
+
+// fetch sends the request and reads the whole response. The returned
+// exchange is never nil, so its timing can be used on errors. If the
+// response shows that the session expired, fetch logs in again and resends
+// the request once.
+func (s *Scanner) fetch(r *Request) (*exchange, error) {
+	for retried := false; ; retried = true {
+		// Prepare request
+		req, err := s.httpRequest(r)
+		if err != nil {
+			return &exchange{}, err
+		}
+		if s.session == nil {
+			return s.send(req)
+		}
+		generation := s.session.apply(req)
+
+		x, err := s.send(req)
+		if err != nil || retried || !s.session.isLoggedOut(x) {
+			return x, err
+		}
+		loggedIn, err := s.session.relogin(generation)
+		if err != nil {
+			return x, fmt.Errorf("the session expired and could not log in again: %v", err)
+		}
+		if loggedIn {
+			s.debugFunc(fmt.Sprintf("[INFO] Session expired at %s, logged in again", r.URL))
+		}
+	}
+}
+
+// httpRequest creates the request to send, with the header order of a raw
+// request where the transport keeps it.
+func (s *Scanner) httpRequest(r *Request) (*http.Request, error) {
+	req, err := r.HTTPRequest()
+	if err != nil {
+		return nil, err
 	}
+	if keepsHeaderOrder(s.options, req) {
+		r.orderHeaders(req)
+	}
+	return req, nil
+}
+
+// send sends a prepared request and reads the whole response. The returned
+// exchange is never nil.
+func (s *Scanner) send(req *http.Request) (*exchange, error) {
+	x := &exchange{}
 
-	// Print response body
-	fmt.Printf("%s\n", doc.Text())
+	// Send request
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	start := time.Now()
+	req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if c, ok := info.Conn.(*wireConn); ok {
+				if state, ok := c.ConnectionState(); ok {
+					x.tlsState = &state
+				}
+			}
+		},
+		GotFirstResponseByte: func() {
+			x.ttfb = time.Since(start)
+		},
+	}))
+	x.req = req
+	resp, err := s.client.Do(req)
+	if err != nil {
+		x.elapsed = time.Since(start)
+		return x, err
+	}
+	defer resp.Body.Close()
+	if resp.TLS == nil {
+		resp.TLS = x.tlsState
+	}
+	x.resp = resp
 
-	return nil
+	// Read response body
+	x.body, err = readBody(resp, time.Duration(s.options.Timeout)*time.Second, cancel)
+	x.elapsed = time.Since(start)
+	return x, err
 }
 
+// isDirectory reports whether a response looks like a directory: a redirect
+// to the same path with a trailing slash, a slash path that is forbidden or
+// served, or a directory listing.
+func isDirectory(reqURL *url.URL, resp *http.Response, body []byte) bool {
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		location, err := resp.Location()
+		if err != nil {
+			return false
+		}
+		return location.Host == reqURL.Host && location.Path == reqURL.Path+"/"
+	case http.StatusForbidden:
+		return strings.HasSuffix(reqURL.Path, "/")
+	case http.StatusOK:
+		if strings.HasSuffix(reqURL.Path, "/") {
+			return true
+		}
+		return isDirectoryListing(body)
+	}
+	return false
+}
 
+// isDirectoryListing reports whether the body is an autoindex page of
+// Apache, nginx or Python's http.server.
+func isDirectoryListing(body []byte) bool {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	title := strings.TrimSpace(doc.Find("title").Text())
+	for _, prefix := range []string{"Index of /", "Directory listing for /"} {
+		if strings.HasPrefix(title, prefix) {
+			return true
+		}
+	}
+	return false
+}
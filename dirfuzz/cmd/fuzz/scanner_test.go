@@ -0,0 +1,245 @@
+package fuzz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/your-username/dirfuzz/output"
+)
+
+// writeFile writes a file into a temporary directory and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// scan runs a quiet scan of the handler with the options and returns the
+// URL paths of the results, sorted.
+func scan(t *testing.T, handler http.HandlerFunc, options Options) []string {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	if options.TargetURL == "" {
+		options.TargetURL = srv.URL
+	} else {
+		options.TargetURL = strings.ReplaceAll(options.TargetURL, "SERVER", srv.URL)
+	}
+	if options.Threads == 0 {
+		options.Threads = 4
+	}
+	options.Timeout = 5
+
+	s, err := NewScanner(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.debugFunc = func(string) {}
+	results, err := s.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resultPaths(results)
+}
+
+func resultPaths(results []output.Result) []string {
+	var paths []string
+	for _, r := range results {
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			paths = append(paths, r.URL)
+			continue
+		}
+		paths = append(paths, u.RequestURI())
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestNewScannerErrors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"no target", Options{Threads: 1}},
+		{"request file", Options{RequestFile: missing, Threads: 1}},
+		{"rule file", Options{TargetURL: "http://h/", RuleFiles: []string{missing}, Threads: 1}},
+		{"backup templates", Options{TargetURL: "http://h/", BackupTemplateFile: missing, Threads: 1}},
+		{"login request", Options{TargetURL: "http://h/", LoginRequestFile: missing, Threads: 1}},
+		{"login options", Options{TargetURL: "http://h/", LoginExtract: "json:$.token", Threads: 1}},
+		{"matcher", Options{TargetURL: "http://h/", Matchers: []string{"status:x"}, Threads: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewScanner(tt.options); err == nil {
+				t.Error("NewScanner() succeeded")
+			}
+		})
+	}
+}
+
+func TestScanRecursion(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin", "/admin/secret":
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		case "/admin/", "/admin/secret/":
+			w.WriteHeader(http.StatusForbidden)
+		case "/admin/secret/config":
+			w.Write([]byte("ok"))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+	wordlist := writeFile(t, "words.txt", "admin\nsecret\nconfig\n")
+
+	tests := []struct {
+		name    string
+		options Options
+		want    []string
+	}{
+		{"no recursion", Options{NoRecursion: true}, []string{"/admin"}},
+		{"max depth", Options{MaxDepth: 1}, []string{"/admin", "/admin/secret"}},
+		{"no limit", Options{}, []string{"/admin", "/admin/secret", "/admin/secret/config"}},
+		{"keyword not last", Options{TargetURL: "SERVER/FUZZ/"}, []string{"/admin/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.WordlistFile = wordlist
+			got := scan(t, handler, tt.options)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("found %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTemplateAppendsKeyword(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"http://h", "http://h/FUZZ"},
+		{"http://h/", "http://h/FUZZ"},
+		{"http://h/app", "http://h/app/FUZZ"},
+		{"http://h/app/?debug=1", "http://h/app/FUZZ?debug=1"},
+		{"http://h/app?debug=1#top", "http://h/app/FUZZ?debug=1#top"},
+		{"http://h/a%2Fb?x=/y", "http://h/a%2Fb/FUZZ?x=/y"},
+		{"http://h/?q=FUZZ", "http://h/?q=FUZZ"},
+	}
+	for _, tt := range tests {
+		r, err := (&Options{TargetURL: tt.url}).newTemplate([]Wordlist{{Keyword: Keyword}})
+		if err != nil {
+			t.Errorf("newTemplate(%q): %v", tt.url, err)
+			continue
+		}
+		if r.URL != tt.want {
+			t.Errorf("newTemplate(%q) URL = %q, want %q", tt.url, r.URL, tt.want)
+		}
+	}
+}
+
+func TestScanRecursionKeepsQuery(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/app/admin":
+			http.Redirect(w, r, "/app/admin/?key=1", http.StatusMovedPermanently)
+		case "/app/admin/config":
+			w.Write([]byte("ok"))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+	wordlist := writeFile(t, "words.txt", "admin\nconfig\n")
+	got := scan(t, handler, Options{TargetURL: "SERVER/app?key=1", WordlistFile: wordlist, Matchers: []string{"status:200,301"}})
+	want := []string{"/app/admin/config?key=1", "/app/admin?key=1"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("found %v, want %v", got, want)
+	}
+}
+
+func TestScanHonorsThreads(t *testing.T) {
+	var mu sync.Mutex
+	active, peak := 0, 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		http.NotFound(w, r)
+	}
+	wordlist := writeFile(t, "words.txt", "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n")
+	scan(t, handler, Options{WordlistFile: wordlist, Threads: 3, NoRecursion: true, NoCalibration: true})
+	if peak > 3 {
+		t.Errorf("%d requests in flight with 3 threads", peak)
+	}
+	if peak < 2 {
+		t.Errorf("requests were not sent concurrently")
+	}
+}
+
+// recordOutput keeps the results written to it.
+type recordOutput struct {
+	mu      sync.Mutex
+	results []output.Result
+	closed  bool
+}
+
+func (o *recordOutput) Write(result output.Result) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.results = append(o.results, result)
+	return nil
+}
+
+func (o *recordOutput) Close() error {
+	o.closed = true
+	return nil
+}
+
+func TestScanWritesResultsToOutput(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(softNotFound))
+	defer srv.Close()
+	wordlist := writeFile(t, "words.txt", "admin\nlogin\n")
+	s, err := NewScanner(Options{TargetURL: srv.URL, WordlistFile: wordlist, Threads: 2, Timeout: 5, NoRecursion: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.debugFunc = func(string) {}
+	out := &recordOutput{}
+	s.SetOutput(out)
+	results, err := s.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.results) != 1 || len(results) != 1 || out.results[0].URL != srv.URL+"/admin" {
+		t.Errorf("wrote %v, returned %v", out.results, results)
+	}
+	if out.closed {
+		t.Error("the scanner closed its output")
+	}
+	if summary := s.Summary(); summary.Total < 2 || summary.Depth(0).Hits != 1 {
+		t.Errorf("summary %+v", summary)
+	}
+}
@@ -0,0 +1,316 @@
+package fuzz
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// tokenPlaceholder is replaced by the extracted token in the header template
+// of Options.LoginInject.
+const tokenPlaceholder = "{token}"
+
+// defaultLoginInject sends an extracted token as a bearer token.
+const defaultLoginInject = "Authorization: Bearer " + tokenPlaceholder
+
+// session logs in with the login request, keeps the token it extracts from
+// the response and adds it to every request. The cookies of the login
+// response go to the cookie jar of the client. When a response matches the
+// logged-out rule, the first worker to see it logs in again while the others
+// wait for it.
+type session struct {
+	request   *Request
+	extract   func(resp *http.Response, body []byte) (string, error)
+	inject    tokenInjector
+	loggedOut Criterion
+	// send sends a request with the client of the scanner.
+	send func(r *Request) (*exchange, error)
+
+	mu    sync.RWMutex
+	token string
+	// generation counts the logins, so that workers that saw the same
+	// expired session log in only once.
+	generation uint64
+}
+
+// newSession builds the session of the scan from the login options, or
+// returns nil without a login request.
+func newSession(o Options) (*session, error) {
+	if o.LoginRequestFile == "" {
+		if o.LoginExtract != "" || o.LoginInject != "" || o.LoggedOut != "" {
+			return nil, errors.New("the login options need a login request")
+		}
+		return nil, nil
+	}
+	login, err := o.loadRawRequest(o.LoginRequestFile)
+	if err != nil {
+		return nil, err
+	}
+	s := &session{request: login}
+
+	if o.LoginExtract != "" {
+		if s.extract, err = parseTokenExtractor(o.LoginExtract); err != nil {
+			return nil, err
+		}
+		inject := o.LoginInject
+		if inject == "" {
+			inject = defaultLoginInject
+		}
+		if s.inject, err = parseTokenInjector(inject); err != nil {
+			return nil, err
+		}
+	} else if o.LoginInject != "" {
+		return nil, errors.New("injecting a login token needs a token to extract")
+	}
+
+	if o.LoggedOut != "" {
+		if s.loggedOut, err = ParseRule(o.LoggedOut); err != nil {
+			return nil, fmt.Errorf("invalid logged-out rule: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// apply adds the token to a request and returns the login generation it
+// belongs to.
+func (s *session) apply(req *http.Request) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.token != "" {
+		s.inject.apply(req, s.token)
+	}
+	return s.generation
+}
+
+// isLoggedOut reports whether the response matches the logged-out rule.
+func (s *session) isLoggedOut(x *exchange) bool {
+	if s.loggedOut == nil {
+		return false
+	}
+	return s.loggedOut.Match(&Response{
+		StatusCode: x.resp.StatusCode,
+		Size:       int64(len(x.body)),
+		Words:      CountWords(x.body),
+		Lines:      CountLines(x.body),
+		Header:     x.resp.Header,
+		Body:       x.body,
+		TTFB:       x.ttfb,
+		Duration:   x.elapsed,
+	})
+}
+
+// relogin logs in again unless another worker already did since generation.
+// It reports whether it logged in.
+func (s *session) relogin(generation uint64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generation != generation {
+		return false, nil
+	}
+	return true, s.loginLocked()
+}
+
+// login sends the login request and keeps its token.
+func (s *session) login() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loginLocked()
+}
+
+func (s *session) loginLocked() error {
+	x, err := s.send(s.request)
+	if err != nil {
+		return err
+	}
+	if x.resp.StatusCode >= 400 {
+		return fmt.Errorf("the login request returned status %d", x.resp.StatusCode)
+	}
+	if s.isLoggedOut(x) {
+		return errors.New("the login response matches the logged-out rule")
+	}
+
+	token := ""
+	if s.extract != nil {
+		if token, err = s.extract(x.resp, x.body); err != nil {
+			return err
+		}
+	}
+	s.token = token
+	s.generation++
+	return nil
+}
+
+// parseTokenExtractor parses how the token is taken from the login response:
+// "regex:PATTERN" matches the response headers and body and takes the first
+// group, or the whole match without groups; "json:PATH" takes a JSONPath
+// such as $.data.token from the body.
+func parseTokenExtractor(spec string) (func(*http.Response, []byte) (string, error), error) {
+	kind, value, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "regex":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token regex: %v", err)
+		}
+		return func(resp *http.Response, body []byte) (string, error) {
+			var buf bytes.Buffer
+			resp.Header.Write(&buf)
+			buf.WriteString("\r\n")
+			buf.Write(body)
+			m := re.FindSubmatch(buf.Bytes())
+			if m == nil {
+				return "", fmt.Errorf("no token matching %s in the login response", value)
+			}
+			if len(m) > 1 {
+				return string(m[1]), nil
+			}
+			return string(m[0]), nil
+		}, nil
+
+	case "json":
+		path, err := parseJSONPath(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(resp *http.Response, body []byte) (string, error) {
+			var doc interface{}
+			if err := json.Unmarshal(body, &doc); err != nil {
+				return "", fmt.Errorf("the login response is not JSON: %v", err)
+			}
+			token, ok := path.lookup(doc)
+			if !ok || token == "" {
+				return "", fmt.Errorf("no token at %s in the login response", value)
+			}
+			return token, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid token extraction %q (want regex:PATTERN or json:PATH)", spec)
+}
+
+// tokenInjector adds the token to a request as a header or a cookie.
+type tokenInjector struct {
+	header string // the header name, or "" for a cookie
+	value  string // the header value template, or the cookie name
+}
+
+// parseTokenInjector parses how the token is sent: "cookie:NAME", or a header
+// such as "Authorization: Bearer {token}".
+func parseTokenInjector(spec string) (tokenInjector, error) {
+	if name, ok := strings.CutPrefix(spec, "cookie:"); ok {
+		if name = strings.TrimSpace(name); name == "" {
+			return tokenInjector{}, errors.New("empty token cookie name")
+		}
+		return tokenInjector{value: name}, nil
+	}
+	name, value, ok := strings.Cut(spec, ":")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !ok || name == "" || !strings.Contains(value, tokenPlaceholder) {
+		return tokenInjector{}, fmt.Errorf("invalid token injection %q (want \"Name: value %s\" or cookie:NAME)", spec, tokenPlaceholder)
+	}
+	return tokenInjector{header: name, value: value}, nil
+}
+
+func (t tokenInjector) apply(req *http.Request, token string) {
+	if t.header == "" {
+		req.AddCookie(&http.Cookie{Name: t.value, Value: token})
+		return
+	}
+	// The template may spell the header in any case.
+	for name := range req.Header {
+		if strings.EqualFold(name, t.header) {
+			delete(req.Header, name)
+		}
+	}
+	req.Header.Set(t.header, strings.ReplaceAll(t.value, tokenPlaceholder, token))
+}
+
+// jsonPath is a JSONPath of member names and array indexes, such as
+// $.data.tokens[0].value or $['access_token'].
+type jsonPath []interface{} // string or int steps
+
+func parseJSONPath(s string) (jsonPath, error) {
+	invalid := fmt.Errorf("invalid JSONPath %q (want e.g. $.data.token)", s)
+	rest, ok := strings.CutPrefix(strings.TrimSpace(s), "$")
+	if !ok {
+		return nil, invalid
+	}
+
+	var path jsonPath
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			i := strings.IndexAny(rest, ".[")
+			if i < 0 {
+				i = len(rest)
+			}
+			if i == 0 {
+				return nil, invalid
+			}
+			path = append(path, rest[:i])
+			rest = rest[i:]
+
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, invalid
+			}
+			step := rest[1:end]
+			rest = rest[end+1:]
+			if len(step) >= 2 && (step[0] == '\'' || step[0] == '"') && step[len(step)-1] == step[0] {
+				path = append(path, step[1:len(step)-1])
+				continue
+			}
+			n, err := strconv.Atoi(step)
+			if err != nil || n < 0 {
+				return nil, invalid
+			}
+			path = append(path, n)
+
+		default:
+			return nil, invalid
+		}
+	}
+	return path, nil
+}
+
+// lookup returns the value at the path as a string. Strings are returned
+// as they are, other values as JSON.
+func (p jsonPath) lookup(doc interface{}) (string, bool) {
+	for _, step := range p {
+		switch step := step.(type) {
+		case string:
+			object, ok := doc.(map[string]interface{})
+			if !ok {
+				return "", false
+			}
+			if doc, ok = object[step]; !ok {
+				return "", false
+			}
+		case int:
+			array, ok := doc.([]interface{})
+			if !ok || step >= len(array) {
+				return "", false
+			}
+			doc = array[step]
+		}
+	}
+
+	switch v := doc.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "", false
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
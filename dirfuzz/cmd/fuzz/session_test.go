@@ -0,0 +1,235 @@
+package fuzz
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"access_token":"a","data":{"tokens":[{"value":"v0"},{"value":"v1"}],"n":42,"ok":true,"none":null},"a.b":"dotted"}`), &doc)
+	tests := []struct {
+		path, want string
+		ok         bool
+	}{
+		{"$.access_token", "a", true},
+		{"$['access_token']", "a", true},
+		{"$.data.tokens[1].value", "v1", true},
+		{`$["data"]["tokens"][0]["value"]`, "v0", true},
+		{"$['a.b']", "dotted", true},
+		{"$.data.n", "42", true},
+		{"$.data.ok", "true", true},
+		{"$.data.tokens[0]", `{"value":"v0"}`, true},
+		{"$.data.none", "", false},
+		{"$.data.tokens[2].value", "", false},
+		{"$.data.tokens.value", "", false},
+		{"$.access_token[0]", "", false},
+		{"$.missing", "", false},
+	}
+	for _, tt := range tests {
+		path, err := parseJSONPath(tt.path)
+		if err != nil {
+			t.Errorf("parseJSONPath(%q) = %v", tt.path, err)
+			continue
+		}
+		got, ok := path.lookup(doc)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	for _, path := range []string{"", "data.token", "$.", "$..token", "$.a[", "$.a[-1]", "$.a[x]", "$token"} {
+		if _, err := parseJSONPath(path); err == nil {
+			t.Errorf("parseJSONPath(%q) succeeded", path)
+		}
+	}
+}
+
+func TestTokenExtractor(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"X-Session": {"hdr-123"}}}
+	body := []byte(`{"data":{"token":"json-456"},"csrf":"abc789"}`)
+	tests := []struct {
+		spec, want string
+		ok         bool
+	}{
+		{`regex:X-Session: (\S+)`, "hdr-123", true},
+		{`regex:abc\d+`, "abc789", true},
+		{`regex:"csrf":"(\w+)"`, "abc789", true},
+		{"json:$.data.token", "json-456", true},
+		{`regex:nothing (\d+)`, "", false},
+		{"json:$.data.missing", "", false},
+	}
+	for _, tt := range tests {
+		extract, err := parseTokenExtractor(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := extract(resp, body)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("%s extracted %q, %v", tt.spec, got, err)
+		}
+	}
+
+	// The JSON extractor needs a JSON body.
+	extract, _ := parseTokenExtractor("json:$.token")
+	if _, err := extract(resp, []byte("<html>")); err == nil {
+		t.Error("token extracted from HTML")
+	}
+	for _, spec := range []string{"regex:(", "json:token", "xpath://token", "$.token"} {
+		if _, err := parseTokenExtractor(spec); err == nil {
+			t.Errorf("parseTokenExtractor(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestTokenInjector(t *testing.T) {
+	tests := []struct {
+		spec   string
+		header string
+		want   string
+	}{
+		{defaultLoginInject, "Authorization", "Bearer tok"},
+		{"x-api-key: {token}", "X-Api-Key", "tok"},
+		{"cookie:sid", "Cookie", "keep=1; sid=tok"},
+	}
+	for _, tt := range tests {
+		inject, err := parseTokenInjector(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest("GET", "http://h/", nil)
+		// The template's own spelling of the header is replaced.
+		req.Header["authorization"] = []string{"Basic old"}
+		req.Header["X-API-KEY"] = []string{"old"}
+		req.Header.Set("Cookie", "keep=1")
+		inject.apply(req, "tok")
+		if got := req.Header.Get(tt.header); got != tt.want {
+			t.Errorf("%s set %s to %q, want %q", tt.spec, tt.header, got, tt.want)
+		}
+		if tt.header != "Cookie" && len(req.Header) != 3 {
+			t.Errorf("%s left headers %v", tt.spec, req.Header)
+		}
+	}
+
+	for _, spec := range []string{"cookie: ", "Authorization: Bearer", ": {token}", "{token}"} {
+		if _, err := parseTokenInjector(spec); err == nil {
+			t.Errorf("parseTokenInjector(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestNewSession(t *testing.T) {
+	if s, err := newSession(Options{}); s != nil || err != nil {
+		t.Errorf("without a login request: %v, %v", s, err)
+	}
+	login := writeFile(t, "login.txt", "POST /login HTTP/1.1\r\nHost: h\r\n\r\nuser=a")
+	s, err := newSession(Options{LoginRequestFile: login, LoginExtract: "json:$.token", LoggedOut: "status:401"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.request.Method != "POST" || s.extract == nil || s.inject.header != "Authorization" || s.loggedOut == nil {
+		t.Errorf("session %+v", s)
+	}
+
+	for name, o := range map[string]Options{
+		"extract without login":  {LoginExtract: "json:$.token"},
+		"rule without login":     {LoggedOut: "status:401"},
+		"inject without extract": {LoginRequestFile: login, LoginInject: "cookie:sid"},
+		"bad extract":            {LoginRequestFile: login, LoginExtract: "regex:("},
+		"bad inject":             {LoginRequestFile: login, LoginExtract: "json:$.token", LoginInject: "X-Token"},
+		"bad rule":               {LoginRequestFile: login, LoggedOut: "nope:1"},
+		"missing request":        {LoginRequestFile: login + ".missing"},
+	} {
+		if _, err := newSession(o); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
+
+// loginServer issues a token and a session cookie on POST /login, and
+// expires the session after every expiry authorized requests.
+type loginServer struct {
+	mu      sync.Mutex
+	logins  int
+	uses    int
+	served  int
+	expiry  int
+	pages   map[string]bool
+	refuse  bool
+	current string
+}
+
+func (s *loginServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path == "/login" {
+		if s.refuse || r.Method != http.MethodPost {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		s.logins++
+		s.uses = 0
+		s.current = fmt.Sprintf("token-%d", s.logins)
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: s.current})
+		fmt.Fprintf(w, `{"data":{"token":%q}}`, s.current)
+		return
+	}
+
+	cookie, err := r.Cookie("sid")
+	if err != nil || cookie.Value != s.current || r.Header.Get("Authorization") != "Bearer "+s.current || s.uses >= s.expiry {
+		http.Error(w, "log in", http.StatusUnauthorized)
+		return
+	}
+	s.uses++
+	s.served++
+	if !s.pages[r.URL.Path] {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write([]byte("secret"))
+}
+
+func TestScanSession(t *testing.T) {
+	server := &loginServer{expiry: 3, pages: map[string]bool{"/admin": true, "/reports": true}}
+	login := writeFile(t, "login.txt", "POST /login HTTP/1.1\r\nHost: app.example.org\r\nContent-Type: application/x-www-form-urlencoded\r\n\r\nuser=a&pass=b")
+	wordlist := writeFile(t, "words.txt", "a\nb\nadmin\nc\nd\ne\nf\nreports\ng\n")
+	got := scan(t, server.ServeHTTP, Options{
+		TargetURL:        "SERVER",
+		WordlistFile:     wordlist,
+		LoginRequestFile: login,
+		LoginExtract:     "json:$.data.token",
+		LoggedOut:        "status:401",
+		// The session cookie is set for the host of the login request.
+		Headers:     []string{"Host: app.example.org"},
+		Threads:     1,
+		NoRecursion: true,
+	})
+	if strings.Join(got, " ") != "/admin /reports" {
+		t.Errorf("found %v", got)
+	}
+	// Every session serves three requests, and is renewed only once it
+	// expires.
+	if want := (server.served + 2) / 3; server.served < 9 || server.logins != want {
+		t.Errorf("logged in %d times for %d requests, want %d", server.logins, server.served, want)
+	}
+}
+
+func TestScanLoginFails(t *testing.T) {
+	srv := httptest.NewServer(&loginServer{refuse: true})
+	defer srv.Close()
+	login := writeFile(t, "login.txt", "POST /login HTTP/1.1\r\nHost: h\r\n\r\n")
+	wordlist := writeFile(t, "words.txt", "admin\n")
+	s, err := NewScanner(Options{TargetURL: srv.URL, WordlistFile: wordlist, LoginRequestFile: login, Threads: 1, Timeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.debugFunc = func(string) {}
+	if _, err := s.Scan(); err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Errorf("Scan() = %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package fuzz
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// tlsVersions are the TLS versions by name.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSVersion parses a TLS version such as "1.2". The empty string
+// leaves the default.
+func parseTLSVersion(s string) (uint16, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(s), "tls")]
+	if !ok {
+		return 0, fmt.Errorf("invalid TLS version %q (want 1.0, 1.1, 1.2 or 1.3)", s)
+	}
+	return v, nil
+}
+
+// parseCipherSuites parses cipher suite names as listed by
+// tls.CipherSuites and tls.InsecureCipherSuites, such as
+// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256".
+func parseCipherSuites(names []string) ([]uint16, error) {
+	suites := make(map[string]uint16)
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites[s.Name] = s.ID
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := suites[strings.ToUpper(name)]
+		if !ok {
+			known := make([]string, 0, len(suites))
+			for s := range suites {
+				known = append(known, s)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown cipher suite %q (want one of %s)", name, strings.Join(known, ", "))
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// validateTLS checks the TLS versions and cipher suites of the options. The
+// certificate files are read, and checked, by newTLSConfig.
+func validateTLS(o Options) error {
+	_, err := newTLSVersions(o)
+	return err
+}
+
+// newTLSVersions returns a TLS configuration with the versions and cipher
+// suites of the options.
+func newTLSVersions(o Options) (*tls.Config, error) {
+	config := &tls.Config{}
+	var err error
+	if config.MinVersion, err = parseTLSVersion(o.TLSMinVersion); err != nil {
+		return nil, err
+	}
+	if config.MaxVersion, err = parseTLSVersion(o.TLSMaxVersion); err != nil {
+		return nil, err
+	}
+	if config.MinVersion != 0 && config.MaxVersion != 0 && config.MinVersion > config.MaxVersion {
+		return nil, errors.New("the minimum TLS version is above the maximum")
+	}
+	if config.CipherSuites, err = parseCipherSuites(o.CipherSuites); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// newTLSConfig builds the TLS configuration of the transport from the
+// options, reading the CA bundle and client certificate. It returns nil if
+// no TLS option is set. The scanner builds it once and shares it between
+// its transports.
+func newTLSConfig(o Options) (*tls.Config, error) {
+	if !o.Insecure && o.CACertFile == "" && o.ClientCertFile == "" && o.SNI == "" &&
+		o.TLSMinVersion == "" && o.TLSMaxVersion == "" && len(o.CipherSuites) == 0 {
+		return nil, nil
+	}
+
+	config, err := newTLSVersions(o)
+	if err != nil {
+		return nil, err
+	}
+	config.InsecureSkipVerify = o.Insecure
+	config.ServerName = o.SNI
+
+	if o.CACertFile != "" {
+		data, err := os.ReadFile(o.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", o.CACertFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if o.ClientCertFile != "" {
+		cert, err := loadClientCertificate(o.ClientCertFile, o.ClientKeyFile, o.ClientCertPassword)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// loadClientCertificate loads a client certificate from a PEM file, with
+// the key in keyFile or in the same file, or from a PKCS#12 file protected
+// by the password.
+func loadClientCertificate(certFile, keyFile, password string) (tls.Certificate, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read client certificate: %v", err)
+	}
+
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		key, cert, chain, err := pkcs12.DecodeChain(data, password)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to decode PKCS#12 client certificate %s: %v", certFile, err)
+		}
+		c := tls.Certificate{PrivateKey: key, Leaf: cert, Certificate: [][]byte{cert.Raw}}
+		for _, ca := range chain {
+			c.Certificate = append(c.Certificate, ca.Raw)
+		}
+		return c, nil
+	}
+
+	keyData := data
+	if keyFile != "" {
+		if keyData, err = os.ReadFile(keyFile); err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to read client key: %v", err)
+		}
+	}
+	cert, err := tls.X509KeyPair(data, keyData)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load client certificate %s: %v", certFile, err)
+	}
+	return cert, nil
+}
+
+// peerCertificate returns the subject and the subject alternative names of
+// the certificate the server presented, if any.
+func peerCertificate(state *tls.ConnectionState) (string, []string) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return "", nil
+	}
+	cert := state.PeerCertificates[0]
+
+	sans := append([]string(nil), cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	return cert.Subject.String(), sans
+}
@@ -0,0 +1,278 @@
+package fuzz
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// testCert is a certificate with its key, signed by a test CA.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func (c testCert) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+}
+
+func (c testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM(), c.keyPEM(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// newTestCert issues a certificate from the template, signed by parent or
+// self-signed without one.
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCert{cert, key}
+}
+
+// newTestPKI returns a CA with a server certificate for internal.test and
+// 127.0.0.1 and a client certificate.
+func newTestPKI(t *testing.T) (ca, server, client testCert) {
+	ca = newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "dirfuzz test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	server = newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "internal.test", Organization: []string{"Acme"}},
+		DNSNames:    []string{"internal.test"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &ca)
+	client = newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "dirfuzz client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &ca)
+	return ca, server, client
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		s    string
+		want uint16
+		ok   bool
+	}{
+		{"", 0, true},
+		{"1.2", tls.VersionTLS12, true},
+		{"TLS1.3", tls.VersionTLS13, true},
+		{"tls1.0", tls.VersionTLS10, true},
+		{"1.4", 0, false},
+		{"SSL3", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseTLSVersion(tt.s)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseTLSVersion(%q) = %x, %v", tt.s, got, err)
+		}
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	ids, err := parseCipherSuites([]string{"tls_ecdhe_rsa_with_aes_128_gcm_sha256", " ", "TLS_RSA_WITH_RC4_128_SHA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || ids[1] != tls.TLS_RSA_WITH_RC4_128_SHA {
+		t.Errorf("parsed %x", ids)
+	}
+	if _, err := parseCipherSuites([]string{"TLS_NOPE"}); err == nil || !strings.Contains(err.Error(), "TLS_AES_128_GCM_SHA256") {
+		t.Errorf("error %v, want the known suites", err)
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	if config, err := newTLSConfig(Options{}); config != nil || err != nil {
+		t.Errorf("without TLS options: %v, %v", config, err)
+	}
+
+	ca, _, _ := newTestPKI(t)
+	bundle := writeFile(t, "ca.pem", string(ca.certPEM()))
+	config, err := newTLSConfig(Options{Insecure: true, SNI: "internal.test", CACertFile: bundle, TLSMinVersion: "1.2", TLSMaxVersion: "1.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.InsecureSkipVerify || config.ServerName != "internal.test" || config.RootCAs == nil ||
+		config.MinVersion != tls.VersionTLS12 || config.MaxVersion != tls.VersionTLS12 {
+		t.Errorf("config %+v", config)
+	}
+
+	// The certificate files are read by NewScanner, not by Validate.
+	missing := Options{TargetURL: "http://h/", Threads: 1, CACertFile: "/nonexistent/ca.pem"}
+	if err := missing.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if _, err := NewScanner(missing); err == nil || !strings.Contains(err.Error(), "CA bundle") {
+		t.Errorf("NewScanner() = %v", err)
+	}
+
+	for name, o := range map[string]Options{
+		"min above max":  {TLSMinVersion: "1.3", TLSMaxVersion: "1.2"},
+		"bad version":    {TLSMaxVersion: "2"},
+		"bad cipher":     {CipherSuites: []string{"RC5"}},
+		"missing bundle": {CACertFile: "/nonexistent/ca.pem"},
+		"empty bundle":   {CACertFile: writeFile(t, "empty.pem", "not a certificate")},
+		"missing cert":   {ClientCertFile: "/nonexistent/client.pem"},
+	} {
+		if _, err := newTLSConfig(o); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
+
+func TestLoadClientCertificate(t *testing.T) {
+	ca, _, client := newTestPKI(t)
+	certFile := writeFile(t, "client.pem", string(client.certPEM()))
+	keyFile := writeFile(t, "client.key", string(client.keyPEM(t)))
+	combined := writeFile(t, "combined.pem", string(client.certPEM())+string(client.keyPEM(t)))
+	p12, err := pkcs12.Modern.Encode(client.key, client.cert, []*x509.Certificate{ca.cert}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p12File := writeFile(t, "client.p12", string(p12))
+
+	tests := []struct {
+		name, cert, key, password string
+		chain                     int
+	}{
+		{"separate key", certFile, keyFile, "", 1},
+		{"combined", combined, "", "", 1},
+		{"PKCS#12", p12File, "", "secret", 2},
+	}
+	for _, tt := range tests {
+		cert, err := loadClientCertificate(tt.cert, tt.key, tt.password)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(cert.Certificate) != tt.chain || cert.PrivateKey == nil {
+			t.Errorf("%s: chain of %d, key %v", tt.name, len(cert.Certificate), cert.PrivateKey != nil)
+		}
+	}
+
+	_, _, other := newTestPKI(t)
+	otherKey := writeFile(t, "other.key", string(other.keyPEM(t)))
+	for name, args := range map[string][3]string{
+		"wrong password": {p12File, "", "guess"},
+		"no key":         {certFile, "", ""},
+		"other key":      {certFile, otherKey, ""},
+		"missing key":    {certFile, "/nonexistent/client.key", ""},
+	} {
+		if _, err := loadClientCertificate(args[0], args[1], args[2]); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
+
+func TestPeerCertificate(t *testing.T) {
+	if subject, sans := peerCertificate(nil); subject != "" || sans != nil {
+		t.Errorf("without TLS: %q, %v", subject, sans)
+	}
+	if subject, sans := peerCertificate(&tls.ConnectionState{}); subject != "" || sans != nil {
+		t.Errorf("without certificates: %q, %v", subject, sans)
+	}
+	_, server, _ := newTestPKI(t)
+	subject, sans := peerCertificate(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{server.cert}})
+	if subject != "CN=internal.test,O=Acme" || strings.Join(sans, " ") != "internal.test 127.0.0.1" {
+		t.Errorf("subject %q, SANs %v", subject, sans)
+	}
+}
+
+func TestScanMutualTLS(t *testing.T) {
+	ca, server, client := newTestPKI(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin" || r.TLS.ServerName != "internal.test" || r.TLS.PeerCertificates[0].Subject.CommonName != "dirfuzz client" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{server.tlsCertificate(t)},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	bundle := writeFile(t, "ca.pem", string(ca.certPEM()))
+	clientFile := writeFile(t, "client.pem", string(client.certPEM())+string(client.keyPEM(t)))
+	wordlist := writeFile(t, "words.txt", "admin\nlogin\n")
+	run := func(o Options) []string {
+		o.TargetURL, o.WordlistFile, o.Threads, o.Timeout, o.NoRecursion = srv.URL, wordlist, 2, 5, true
+		s, err := NewScanner(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.debugFunc = func(string) {}
+		results, err := s.Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found []string
+		for _, r := range results {
+			found = append(found, strings.TrimPrefix(r.URL, srv.URL)+" "+r.CertSubject+" "+strings.Join(r.CertSANs, ","))
+		}
+		return found
+	}
+
+	got := run(Options{CACertFile: bundle, ClientCertFile: clientFile, SNI: "internal.test"})
+	if strings.Join(got, "\n") != "/admin CN=internal.test,O=Acme internal.test,127.0.0.1" {
+		t.Errorf("found %q", got)
+	}
+	// Without the client certificate or the CA the handshake fails.
+	if got := run(Options{CACertFile: bundle, SNI: "internal.test"}); len(got) != 0 {
+		t.Errorf("found %q without a client certificate", got)
+	}
+	if got := run(Options{ClientCertFile: clientFile, SNI: "internal.test"}); len(got) != 0 {
+		t.Errorf("found %q without the CA", got)
+	}
+}
@@ -0,0 +1,331 @@
+package fuzz
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP versions that can be forced with Options.HTTPVersion.
+const (
+	HTTP10 = "1.0"
+	HTTP11 = "1.1"
+	HTTP2  = "2"
+)
+
+// DefaultClient sends the requests of Request.Do. It shares its connections
+// between all requests and follows redirects.
+var DefaultClient = &http.Client{
+	Transport: newTransport(Options{Threads: 10, Timeout: 10}, nil),
+	Timeout:   10 * time.Second,
+}
+
+// NewClient returns a client following redirects with the transport the
+// scanner uses, configured by the HTTP version, timeout, proxy, TLS and
+// authentication options. It can replace DefaultClient to configure Request.Do.
+func NewClient(options Options) (*http.Client, error) {
+	if err := validateHTTPVersion(options.HTTPVersion); err != nil {
+		return nil, err
+	}
+	if _, err := parseProxies(options.Proxies); err != nil {
+		return nil, err
+	}
+	if err := validateAuth(options); err != nil {
+		return nil, err
+	}
+	config, err := newTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: newClientTransport(options, config)}, nil
+}
+
+// validateHTTPVersion checks the HTTP version option.
+func validateHTTPVersion(version string) error {
+	switch version {
+	case "", HTTP10, HTTP11, HTTP2:
+		return nil
+	}
+	return fmt.Errorf("invalid HTTP version %q (want %s, %s or %s)", version, HTTP10, HTTP11, HTTP2)
+}
+
+// newTransport returns a transport shared by all workers, with enough idle
+// connections to keep every worker's connection alive between requests.
+// The timeout applies to dialing, the TLS handshake and waiting for the
+// response headers separately; the body is bounded by readBody. config is
+// the TLS configuration of newTLSConfig, nil for the default.
+func newTransport(options Options, config *tls.Config) *http.Transport {
+	timeout := time.Duration(options.Timeout) * time.Second
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = timeout
+	transport.ResponseHeaderTimeout = timeout
+	transport.ExpectContinueTimeout = time.Second
+	transport.MaxIdleConns = options.Threads
+	transport.MaxIdleConnsPerHost = options.Threads
+	transport.MaxConnsPerHost = options.Threads
+	transport.IdleConnTimeout = 90 * time.Second
+	// Without compression the reported sizes are the sizes on the wire.
+	transport.DisableCompression = options.DisableCompression
+	// Transports may change their configuration, e.g. to offer HTTP/2, so
+	// each gets a copy.
+	if config != nil {
+		transport.TLSClientConfig = config.Clone()
+	}
+	// Without proxies, the proxy of the environment is used. The proxies
+	// were checked by Options.Validate.
+	if proxies, err := parseProxies(options.Proxies); err == nil && len(proxies) > 0 {
+		transport.Proxy = proxyFunc(proxies, options.ProxySample)
+	}
+
+	switch options.HTTPVersion {
+	case HTTP2:
+		transport.ForceAttemptHTTP2 = true
+		return transport
+	case HTTP10:
+		// HTTP/1.0 has no persistent connections.
+		transport.DisableKeepAlives = true
+	}
+	// An empty TLSNextProto map keeps the transport from negotiating
+	// HTTP/2.
+	transport.ForceAttemptHTTP2 = false
+	transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+
+	// Go writes HTTP/1.1 request lines and sorts the headers, so for
+	// HTTP/1.0 and raw requests the connections are wrapped to rewrite the
+	// head of each request.
+	http10 := options.HTTPVersion == HTTP10
+	if !http10 && !options.rawRequests() {
+		return transport
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &wireConn{Conn: conn, http10: http10}, nil
+	}
+	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialTLS(ctx, dialer, transport.TLSClientConfig, timeout, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &wireConn{Conn: conn, http10: http10}, nil
+	}
+	return transport
+}
+
+// keepsHeaderOrder reports whether the transport of the options writes the
+// head of req in plain text to a wrapped connection, where the header order
+// of a raw request can be kept. HTTP/2 has its own header encoding, and
+// through a proxy HTTPS is tunneled and the other proxy types wrap the
+// request.
+func keepsHeaderOrder(options Options, req *http.Request) bool {
+	if !options.rawRequests() || options.HTTPVersion == HTTP2 {
+		return false
+	}
+	// The proxies were checked by Options.Validate.
+	proxies, _ := parseProxies(options.Proxies)
+	if len(proxies) == 0 {
+		if u, err := http.ProxyFromEnvironment(req); err == nil && u != nil {
+			proxies = append(proxies, u)
+		}
+	}
+	for _, u := range proxies {
+		if req.URL.Scheme != "http" || u.Scheme != "http" {
+			return false
+		}
+	}
+	return true
+}
+
+// dialTLS dials addr and completes the TLS handshake within the timeout.
+func dialTLS(ctx context.Context, dialer *net.Dialer, config *tls.Config, timeout time.Duration, network, addr string) (net.Conn, error) {
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// headerOrderHeader carries the order of the request headers from
+// Request.orderHeaders to the connection, which drops it.
+const headerOrderHeader = "X-Dirfuzz-Header-Order"
+
+// wireConn rewrites the head of every HTTP/1.x request written to it. With
+// http10 the request line is rewritten to HTTP/1.0, and the headers are put
+// in the order given by headerOrderHeader, with the names written as given
+// there. Once anything else is written, such as a CONNECT tunnel, a SOCKS
+// handshake or a chunked body, the rest is passed through unchanged; the
+// scanner only asks for an order where that cannot happen, see
+// keepsHeaderOrder.
+type wireConn struct {
+	net.Conn
+	http10 bool
+
+	head []byte // the head of the current request, until it is complete
+	body int64  // the bytes of the current body still to pass through
+	raw  bool   // pass everything through
+}
+
+func (c *wireConn) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		switch {
+		case c.raw:
+			if _, err := c.Conn.Write(p); err != nil {
+				return 0, err
+			}
+			return n, nil
+
+		case c.body > 0:
+			k := int64(len(p))
+			if k > c.body {
+				k = c.body
+			}
+			if _, err := c.Conn.Write(p[:k]); err != nil {
+				return 0, err
+			}
+			c.body -= k
+			p = p[k:]
+
+		case len(c.head) == 0 && !isLetter(p[0]):
+			c.raw = true
+
+		default:
+			c.head = append(c.head, p...)
+			i := bytes.Index(c.head, []byte("\r\n\r\n"))
+			if i < 0 {
+				return n, nil
+			}
+			head, rest := c.head[:i+4], c.head[i+4:]
+			c.head = nil
+
+			head, c.body, c.raw = c.rewrite(head)
+			if _, err := c.Conn.Write(head); err != nil {
+				return 0, err
+			}
+			p = rest
+		}
+	}
+	return n, nil
+}
+
+// rewrite returns the rewritten head of a request, the length of its body,
+// and whether the connection carries something else after it.
+func (c *wireConn) rewrite(head []byte) ([]byte, int64, bool) {
+	lines := strings.Split(strings.TrimSuffix(string(head), "\r\n\r\n"), "\r\n")
+	if c.http10 && strings.HasSuffix(lines[0], " HTTP/1.1") {
+		lines[0] = strings.TrimSuffix(lines[0], "1.1") + "1.0"
+	}
+
+	var order []string
+	headers := make([]string, 0, len(lines)-1)
+	var body int64
+	raw := strings.HasPrefix(lines[0], "CONNECT ")
+	for _, line := range lines[1:] {
+		name, value, _ := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.EqualFold(name, headerOrderHeader):
+			order = strings.Split(value, ",")
+			continue
+		case strings.EqualFold(name, "Content-Length"):
+			body, _ = strconv.ParseInt(value, 10, 64)
+		case strings.EqualFold(name, "Transfer-Encoding"):
+			raw = true
+		}
+		headers = append(headers, line)
+	}
+
+	// The headers named in the order come first, then the others as Go
+	// wrote them.
+	if order != nil {
+		used := make([]bool, len(headers))
+		sorted := make([]string, 0, len(headers))
+		for _, want := range order {
+			for i, line := range headers {
+				name, value, _ := strings.Cut(line, ":")
+				if !used[i] && strings.EqualFold(name, want) {
+					used[i] = true
+					sorted = append(sorted, want+":"+value)
+					break
+				}
+			}
+		}
+		for i, line := range headers {
+			if !used[i] {
+				sorted = append(sorted, line)
+			}
+		}
+		headers = sorted
+	}
+
+	out := lines[0] + "\r\n"
+	for _, line := range headers {
+		out += line + "\r\n"
+	}
+	return []byte(out + "\r\n"), body, raw
+}
+
+// ConnectionState returns the TLS state of the connection, if it is a TLS
+// connection. The transport only reports the state of unwrapped ones.
+func (c *wireConn) ConnectionState() (tls.ConnectionState, bool) {
+	if tc, ok := c.Conn.(*tls.Conn); ok {
+		return tc.ConnectionState(), true
+	}
+	return tls.ConnectionState{}, false
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// readBody reads the body of a response, canceling the request if it takes
+// longer than the timeout. Zero means no timeout.
+func readBody(resp *http.Response, timeout time.Duration, cancel context.CancelFunc) ([]byte, error) {
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, cancel)
+		defer timer.Stop()
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil && timeout > 0 && errors.Is(err, context.Canceled) {
+		return body, fmt.Errorf("reading the body took longer than %v", timeout)
+	}
+	return body, err
+}
@@ -0,0 +1,310 @@
+package fuzz
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureConn records what is written to it.
+type captureConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (c *captureConn) Write(p []byte) (int, error) {
+	return c.buf.Write(p)
+}
+
+func TestWireConnRewrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		http10 bool
+		writes []string
+		want   string
+	}{
+		{
+			name: "header case and order",
+			writes: []string{"GET / HTTP/1.1\r\nHost: example.com\r\nAccept: */*\r\nUser-Agent: x\r\n" +
+				"X-Dirfuzz-Header-Order: user-agent,HOST,accept\r\n\r\n"},
+			want: "GET / HTTP/1.1\r\nuser-agent: x\r\nHOST: example.com\r\naccept: */*\r\n\r\n",
+		},
+		{
+			name: "headers not in the order follow",
+			writes: []string{"GET / HTTP/1.1\r\nHost: example.com\r\nAccept-Encoding: gzip\r\nX-A: 1\r\n" +
+				"X-Dirfuzz-Header-Order: x-a\r\n\r\n"},
+			want: "GET / HTTP/1.1\r\nx-a: 1\r\nHost: example.com\r\nAccept-Encoding: gzip\r\n\r\n",
+		},
+		{
+			name: "duplicate headers",
+			writes: []string{"GET / HTTP/1.1\r\nHost: h\r\nCookie: a=1\r\nCookie: b=2\r\nX-B: 3\r\n" +
+				"X-Dirfuzz-Header-Order: cookie,X-B,COOKIE,host\r\n\r\n"},
+			want: "GET / HTTP/1.1\r\ncookie: a=1\r\nX-B: 3\r\nCOOKIE: b=2\r\nhost: h\r\n\r\n",
+		},
+		{
+			name:   "HTTP/1.0",
+			http10: true,
+			writes: []string{"GET /a HTTP/1.1\r\nHost: h\r\n\r\n", "GET /b HTTP/1.1\r\nHost: h\r\n\r\n"},
+			want:   "GET /a HTTP/1.0\r\nHost: h\r\n\r\nGET /b HTTP/1.0\r\nHost: h\r\n\r\n",
+		},
+		{
+			name: "head and body split across writes",
+			writes: []string{"POST / HTTP/1.1\r\nHost: h\r\nContent-", "Length: 11\r\nX-Dirfuzz-Header-Order: content-length,host\r",
+				"\n\r\nhello", " wor", "ldGET / HTTP/1.1\r\nHost: h\r\n\r\n"},
+			want: "POST / HTTP/1.1\r\ncontent-length: 11\r\nhost: h\r\n\r\nhello worldGET / HTTP/1.1\r\nHost: h\r\n\r\n",
+		},
+		{
+			name:   "body that looks like a request",
+			writes: []string{"POST / HTTP/1.1\r\nHost: h\r\nContent-Length: 25\r\n\r\n", "GET / HTTP/1.1\r\nX: y\r\n\r\n"},
+			want:   "POST / HTTP/1.1\r\nHost: h\r\nContent-Length: 25\r\n\r\nGET / HTTP/1.1\r\nX: y\r\n\r\n",
+		},
+		{
+			name:   "chunked body passes through",
+			writes: []string{"POST / HTTP/1.1\r\nHost: h\r\nTransfer-Encoding: chunked\r\n\r\n", "5\r\nX-A: \r\n0\r\n\r\n"},
+			want:   "POST / HTTP/1.1\r\nHost: h\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nX-A: \r\n0\r\n\r\n",
+		},
+		{
+			name:   "CONNECT tunnel passes through",
+			http10: true,
+			writes: []string{"CONNECT h:443 HTTP/1.1\r\nHost: h:443\r\n\r\n", "GET / HTTP/1.1\r\n\r\n"},
+			want:   "CONNECT h:443 HTTP/1.0\r\nHost: h:443\r\n\r\nGET / HTTP/1.1\r\n\r\n",
+		},
+		{
+			name:   "binary data passes through",
+			http10: true,
+			writes: []string{"\x05\x01\x00", "GET / HTTP/1.1\r\n\r\n"},
+			want:   "\x05\x01\x00GET / HTTP/1.1\r\n\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capture := &captureConn{}
+			conn := &wireConn{Conn: capture, http10: tt.http10}
+			for _, w := range tt.writes {
+				n, err := conn.Write([]byte(w))
+				if err != nil || n != len(w) {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			if got := capture.buf.String(); got != tt.want {
+				t.Errorf("wrote\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRawRequestHeaderOrder sends a raw request through the transport and
+// checks the head the server receives.
+func TestRawRequestHeaderOrder(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	heads := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var head strings.Builder
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			head.WriteString(line)
+			if err != nil || line == "\r\n" {
+				break
+			}
+		}
+		heads <- head.String()
+		conn.Write([]byte("HTTP/1.1 204 No Content\r\nConnection: close\r\n\r\n"))
+	}()
+
+	r, err := ParseRawRequest([]byte("GET /a HTTP/1.1\r\nhost: "+ln.Addr().String()+"\r\nx-b: 1\r\nuser-agent: raw\r\nAccept: */*\r\n\r\n"), "http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := Options{RequestFile: "request.txt", Threads: 1, Timeout: 5}
+	scanner := &Scanner{options: options}
+	req, err := scanner.httpRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Close = true
+	resp, err := (&http.Client{Transport: newTransport(options, nil)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	want := "GET /a HTTP/1.1\r\nhost: " + ln.Addr().String() + "\r\nx-b: 1\r\nuser-agent: raw\r\nAccept: */*\r\n"
+	if got := <-heads; !strings.HasPrefix(got, want) || strings.Contains(got, headerOrderHeader) {
+		t.Errorf("server received\n%q\nwant prefix\n%q", got, want)
+	}
+}
+
+func TestKeepsHeaderOrder(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "")
+	t.Setenv("HTTPS_PROXY", "")
+	tests := []struct {
+		name    string
+		options Options
+		url     string
+		want    bool
+	}{
+		{"no raw request", Options{}, "http://h/", false},
+		{"raw request", Options{RequestFile: "r"}, "https://h/", true},
+		{"HTTP/2", Options{RequestFile: "r", HTTPVersion: HTTP2}, "http://h/", false},
+		{"HTTP proxy", Options{RequestFile: "r", Proxies: []string{"http://p:8080"}}, "http://h/", true},
+		{"HTTPS through a proxy", Options{RequestFile: "r", Proxies: []string{"http://p:8080"}}, "https://h/", false},
+		{"SOCKS proxy", Options{RequestFile: "r", Proxies: []string{"socks5://p:1080"}}, "http://h/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := keepsHeaderOrder(tt.options, req); got != tt.want {
+				t.Errorf("keepsHeaderOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateHTTPVersion(t *testing.T) {
+	for _, version := range []string{"", HTTP10, HTTP11, HTTP2} {
+		if err := validateHTTPVersion(version); err != nil {
+			t.Errorf("validateHTTPVersion(%q) = %v", version, err)
+		}
+	}
+	for _, version := range []string{"2.0", "HTTP/1.1", "3"} {
+		if err := validateHTTPVersion(version); err == nil {
+			t.Errorf("validateHTTPVersion(%q) succeeded", version)
+		}
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	transport := newTransport(Options{Threads: 7, Timeout: 3, DisableCompression: true}, nil)
+	if transport.MaxIdleConns != 7 || transport.MaxIdleConnsPerHost != 7 || transport.MaxConnsPerHost != 7 {
+		t.Errorf("pool of %d idle, %d idle per host, %d per host", transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost)
+	}
+	if transport.TLSHandshakeTimeout != 3*time.Second || transport.ResponseHeaderTimeout != 3*time.Second {
+		t.Errorf("handshake timeout %v, header timeout %v", transport.TLSHandshakeTimeout, transport.ResponseHeaderTimeout)
+	}
+	if !transport.DisableCompression || transport.DisableKeepAlives {
+		t.Errorf("compression disabled %v, keep-alives disabled %v", transport.DisableCompression, transport.DisableKeepAlives)
+	}
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil {
+		t.Error("HTTP/2 is negotiated by default")
+	}
+
+	transport = newTransport(Options{Threads: 1, HTTPVersion: HTTP2}, nil)
+	if !transport.ForceAttemptHTTP2 || transport.TLSNextProto != nil {
+		t.Error("HTTP/2 is not negotiated")
+	}
+	transport = newTransport(Options{Threads: 1, HTTPVersion: HTTP10}, nil)
+	if !transport.DisableKeepAlives || transport.DialTLSContext == nil {
+		t.Error("HTTP/1.0 keeps connections alive or is not rewritten")
+	}
+}
+
+func TestTransportHTTPVersions(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	for version, want := range map[string]string{
+		"":     "HTTP/1.1",
+		HTTP10: "HTTP/1.0",
+		HTTP11: "HTTP/1.1",
+		HTTP2:  "HTTP/2.0",
+	} {
+		client := &http.Client{Transport: newTransport(Options{Threads: 1, Timeout: 5, HTTPVersion: version}, &tls.Config{InsecureSkipVerify: true})}
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("%q: %v", version, err)
+		}
+		body, err := readBody(resp, 0, nil)
+		resp.Body.Close()
+		if err != nil || string(body) != want {
+			t.Errorf("%q: server saw %q, %v, want %s", version, body, err, want)
+		}
+	}
+}
+
+func TestTransportReusesConnections(t *testing.T) {
+	for version, want := range map[string]int{HTTP11: 1, HTTP10: 5} {
+		var mu sync.Mutex
+		conns := 0
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}))
+		srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				mu.Lock()
+				conns++
+				mu.Unlock()
+			}
+		}
+		srv.Start()
+
+		client := &http.Client{Transport: newTransport(Options{Threads: 2, Timeout: 5, HTTPVersion: version}, nil)}
+		for i := 0; i < 5; i++ {
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			readBody(resp, 0, nil)
+			resp.Body.Close()
+		}
+		srv.Close()
+		if conns != want {
+			t.Errorf("%s opened %d connections for 5 requests, want %d", version, conns, want)
+		}
+	}
+}
+
+func TestReadBodyTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	resp, err := newTransport(Options{Threads: 1, Timeout: 5}, nil).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	start := time.Now()
+	body, err := readBody(resp, 100*time.Millisecond, cancel)
+	if err == nil || !strings.Contains(err.Error(), "took longer") || string(body) != "partial" {
+		t.Errorf("read %q, %v", body, err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("reading took %v", elapsed)
+	}
+}
@@ -0,0 +1,255 @@
+package fuzz
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Modes of combining several wordlists.
+const (
+	// ModeClusterbomb sends every combination of the wordlists' entries.
+	ModeClusterbomb = "clusterbomb"
+	// ModePitchfork sends the n-th entries of all wordlists together and
+	// stops at the end of the shortest wordlist.
+	ModePitchfork = "pitchfork"
+	// ModeSniper fuzzes one keyword at a time, while the other keywords
+	// keep the first entry of their wordlist.
+	ModeSniper = "sniper"
+)
+
+// keywordPattern matches the keywords wordlists can be bound to.
+var keywordPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Wordlist binds a wordlist to the keyword its entries replace.
+type Wordlist struct {
+	Path    string
+	Keyword string
+
+	dictionary *Dictionary
+}
+
+// ParseWordlist parses a wordlist given as "path" or "path:KEYWORD". The
+// keyword defaults to FUZZ, and a path of "-" reads the wordlist from stdin.
+// The path may select archive entries, as in "lists.zip:web/*.txt:FUZZ", or
+// name a generator, as in "range:1-100:ID".
+//
+// The entry pattern of an archive always comes before the keyword, so
+// "lists.zip:README" selects the entry README, while "lists.zip:*:USER"
+// binds every entry to USER. On stdin, which cannot be inspected
+// beforehand, an entry named like a keyword needs the keyword spelled out,
+// as in "-:README:FUZZ".
+func ParseWordlist(spec string) Wordlist {
+	i := strings.LastIndex(spec, ":")
+	if i <= 0 || !keywordPattern.MatchString(spec[i+1:]) {
+		return Wordlist{Path: spec, Keyword: Keyword}
+	}
+	path := spec[:i]
+	if name, pattern := splitArchivePath(path); pattern == "" && name != Stdin && isArchive(name) {
+		return Wordlist{Path: spec, Keyword: Keyword}
+	}
+	return Wordlist{Path: path, Keyword: spec[i+1:]}
+}
+
+// openWordlists opens the dictionaries of the wordlists and applies the
+// mutation rules to them. They must be closed with closeWordlists.
+func openWordlists(lists []Wordlist, dedupe string, rules Mutations) error {
+	for i := range lists {
+		d, err := NewDictionary(lists[i].Path)
+		if err == nil {
+			err = d.SetDedupe(dedupe)
+		}
+		if err != nil {
+			closeWordlists(lists[:i])
+			return err
+		}
+		d.SetRules(rules)
+		lists[i].dictionary = d
+	}
+	return nil
+}
+
+// closeWordlists closes the dictionaries of the wordlists.
+func closeWordlists(lists []Wordlist) {
+	for i := range lists {
+		if lists[i].dictionary != nil {
+			lists[i].dictionary.Close()
+			lists[i].dictionary = nil
+		}
+	}
+}
+
+// combineWordlists calls fn with every combination of keyword values the mode
+// produces. The map passed to fn must not be kept.
+func combineWordlists(mode string, lists []Wordlist, fn func(values map[string]string) error) error {
+	switch mode {
+	case ModePitchfork:
+		return pitchfork(lists, fn)
+	case ModeSniper:
+		return sniper(lists, fn)
+	default:
+		return clusterbomb(lists, make(map[string]string), fn)
+	}
+}
+
+// clusterbomb iterates the first wordlist and, for each of its entries,
+// every combination of the remaining ones. Inner wordlists are reread for
+// every outer entry, so nothing has to be kept in memory.
+func clusterbomb(lists []Wordlist, values map[string]string, fn func(map[string]string) error) error {
+	if len(lists) == 0 {
+		return fn(values)
+	}
+
+	it := lists[0].dictionary.Iterate()
+	defer it.Close()
+
+	for it.Next() {
+		values[lists[0].Keyword] = it.Word()
+		if err := clusterbomb(lists[1:], values, fn); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// pitchfork iterates all wordlists side by side.
+func pitchfork(lists []Wordlist, fn func(map[string]string) error) error {
+	its := make([]*Iterator, len(lists))
+	for i, list := range lists {
+		its[i] = list.dictionary.Iterate()
+		defer its[i].Close()
+	}
+
+	values := make(map[string]string, len(lists))
+	for {
+		for i, it := range its {
+			if !it.Next() {
+				return it.Err()
+			}
+			values[lists[i].Keyword] = it.Word()
+		}
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+}
+
+// sniper iterates one wordlist at a time. The keywords of the other
+// wordlists are set to their first entry.
+func sniper(lists []Wordlist, fn func(map[string]string) error) error {
+	defaults, err := firstEntries(lists)
+	if err != nil {
+		return err
+	}
+
+	for _, list := range lists {
+		values := make(map[string]string, len(lists))
+		for keyword, value := range defaults {
+			values[keyword] = value
+		}
+
+		it := list.dictionary.Iterate()
+		for it.Next() {
+			values[list.Keyword] = it.Word()
+			if err := fn(values); err != nil {
+				it.Close()
+				return err
+			}
+		}
+		it.Close()
+		if err := it.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasKeyword reports whether a wordlist is bound to the keyword.
+func hasKeyword(lists []Wordlist, keyword string) bool {
+	for _, list := range lists {
+		if list.Keyword == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+// firstEntries returns the first entry of every wordlist by keyword.
+func firstEntries(lists []Wordlist) (map[string]string, error) {
+	values := make(map[string]string, len(lists))
+	for _, list := range lists {
+		it := list.dictionary.Iterate()
+		if it.Next() {
+			values[list.Keyword] = it.Word()
+		}
+		it.Close()
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// countJobs returns the number of combinations the mode produces. Duplicate
+// entries and rule candidates are counted, so the result is an upper bound.
+// Counting a wordlist read from stdin means reading all of it before the
+// scan starts, so unless exact is set the count is -1 for such a list.
+func countJobs(mode string, lists []Wordlist, exact bool) (int64, error) {
+	var jobs int64
+	for _, list := range lists {
+		if !exact && list.dictionary.streaming() {
+			return -1, nil
+		}
+	}
+	for i, list := range lists {
+		n, err := list.dictionary.Size()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case i == 0:
+			jobs = n
+		case mode == ModePitchfork:
+			if n < jobs {
+				jobs = n
+			}
+		case mode == ModeSniper:
+			jobs += n
+		default:
+			jobs *= n
+		}
+	}
+	return jobs, nil
+}
+
+// validateWordlists checks that keywords are unique and none is a prefix of
+// another, stdin is read at most once and the mode is known.
+func validateWordlists(mode string, lists []Wordlist) error {
+	switch mode {
+	case "", ModeClusterbomb, ModePitchfork, ModeSniper:
+	default:
+		return fmt.Errorf("invalid mode %q (want %s, %s or %s)", mode, ModeClusterbomb, ModePitchfork, ModeSniper)
+	}
+
+	seen := make(map[string]bool)
+	stdin := false
+	for _, list := range lists {
+		if seen[list.Keyword] {
+			return fmt.Errorf("keyword %s is bound to more than one wordlist", list.Keyword)
+		}
+		seen[list.Keyword] = true
+		for _, other := range lists {
+			if other.Keyword != list.Keyword && strings.HasPrefix(other.Keyword, list.Keyword) {
+				return fmt.Errorf("keyword %s is a prefix of keyword %s", list.Keyword, other.Keyword)
+			}
+		}
+		if name, _ := splitArchivePath(list.Path); name == Stdin {
+			if stdin {
+				return errors.New("only one wordlist can be read from stdin")
+			}
+			stdin = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package fuzz
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// openTestWordlists opens wordlists of the given entries, bound to the
+// keywords A, B, C and so on.
+func openTestWordlists(t *testing.T, entries ...string) []Wordlist {
+	t.Helper()
+	var lists []Wordlist
+	for i, content := range entries {
+		keyword := string(rune('A' + i))
+		path := writeFile(t, keyword+".txt", strings.ReplaceAll(content, " ", "\n"))
+		lists = append(lists, Wordlist{Path: path, Keyword: keyword})
+	}
+	if err := openWordlists(lists, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { closeWordlists(lists) })
+	return lists
+}
+
+// combinations returns what combineWordlists produces as "A=a,B=b" strings.
+func combinations(t *testing.T, mode string, lists []Wordlist) []string {
+	t.Helper()
+	var got []string
+	err := combineWordlists(mode, lists, func(values map[string]string) error {
+		var pairs []string
+		for _, list := range lists {
+			pairs = append(pairs, list.Keyword+"="+values[list.Keyword])
+		}
+		got = append(got, strings.Join(pairs, ","))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestCombineWordlists(t *testing.T) {
+	lists := openTestWordlists(t, "a1 a2", "b1 b2 b3")
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{ModeClusterbomb, "A=a1,B=b1 A=a1,B=b2 A=a1,B=b3 A=a2,B=b1 A=a2,B=b2 A=a2,B=b3"},
+		{"", "A=a1,B=b1 A=a1,B=b2 A=a1,B=b3 A=a2,B=b1 A=a2,B=b2 A=a2,B=b3"},
+		{ModePitchfork, "A=a1,B=b1 A=a2,B=b2"},
+		{ModeSniper, "A=a1,B=b1 A=a2,B=b1 A=a1,B=b1 A=a1,B=b2 A=a1,B=b3"},
+	}
+	for _, tt := range tests {
+		got := combinations(t, tt.mode, lists)
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("%q made %q, want %q", tt.mode, got, tt.want)
+		}
+		jobs, err := countJobs(tt.mode, lists, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if jobs != int64(len(got)) {
+			t.Errorf("%q counted %d jobs, made %d", tt.mode, jobs, len(got))
+		}
+	}
+}
+
+func TestCombineWordlistsStops(t *testing.T) {
+	lists := openTestWordlists(t, "a1 a2", "b1 b2")
+	stop := errors.New("stop")
+	for _, mode := range []string{ModeClusterbomb, ModePitchfork, ModeSniper} {
+		calls := 0
+		err := combineWordlists(mode, lists, func(map[string]string) error {
+			calls++
+			return stop
+		})
+		if err != stop || calls != 1 {
+			t.Errorf("%s: error %v after %d calls", mode, err, calls)
+		}
+	}
+}
+
+func TestCombineWordlistsEmpty(t *testing.T) {
+	lists := openTestWordlists(t, "a1 a2", "#")
+	for _, mode := range []string{ModeClusterbomb, ModePitchfork} {
+		if got := combinations(t, mode, lists); len(got) != 0 {
+			t.Errorf("%s made %q with an empty wordlist", mode, got)
+		}
+	}
+	// The keyword of an empty wordlist is left empty.
+	if got := combinations(t, ModeSniper, lists); strings.Join(got, " ") != "A=a1,B= A=a2,B=" {
+		t.Errorf("sniper made %q", got)
+	}
+}
+
+func TestValidateWordlists(t *testing.T) {
+	tests := []struct {
+		mode  string
+		lists []Wordlist
+		ok    bool
+	}{
+		{"", []Wordlist{{Path: "a", Keyword: "A"}, {Path: "a", Keyword: "B"}}, true},
+		{ModeSniper, []Wordlist{{Path: "-", Keyword: "A"}, {Path: "b", Keyword: "B"}}, true},
+		{"battering-ram", []Wordlist{{Path: "a", Keyword: "A"}}, false},
+		{"", []Wordlist{{Path: "a", Keyword: "A"}, {Path: "b", Keyword: "A"}}, false},
+		{"", []Wordlist{{Path: "-", Keyword: "A"}, {Path: "-:*.txt", Keyword: "B"}}, false},
+		{"", []Wordlist{{Path: "a", Keyword: "FUZZ2"}, {Path: "b", Keyword: "FUZZ"}}, false},
+		{"", []Wordlist{{Path: "a", Keyword: "USER"}, {Path: "b", Keyword: "USERNAME"}}, false},
+		{"", []Wordlist{{Path: "a", Keyword: "USER"}, {Path: "b", Keyword: "NAME_USER"}}, true},
+	}
+	for _, tt := range tests {
+		err := validateWordlists(tt.mode, tt.lists)
+		if (err == nil) != tt.ok {
+			t.Errorf("validateWordlists(%q, %v) = %v", tt.mode, tt.lists, err)
+		}
+	}
+}
+
+func TestScanModes(t *testing.T) {
+	users := writeFile(t, "users.txt", "admin\nguest\n")
+	passwords := writeFile(t, "passwords.txt", "secret\nletmein\n")
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("user") == "guest" && q.Get("pass") == "letmein" {
+			fmt.Fprint(w, "ok")
+			return
+		}
+		http.NotFound(w, r)
+	}
+	for mode, want := range map[string]string{
+		ModeClusterbomb: "/login?user=guest&pass=letmein",
+		ModePitchfork:   "/login?user=guest&pass=letmein",
+		ModeSniper:      "",
+	} {
+		got := scan(t, handler, Options{
+			TargetURL:   "SERVER/login?user=USER&pass=PASS",
+			Wordlists:   []string{users + ":USER", passwords + ":PASS"},
+			Mode:        mode,
+			NoRecursion: true,
+		})
+		if strings.Join(got, " ") != want {
+			t.Errorf("%s found %v, want %q", mode, got, want)
+		}
+	}
+}
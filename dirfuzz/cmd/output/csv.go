@@ -4,31 +4,30 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/gookit/color"
 )
 
 // CSVOutput represents CSV output writer.
 type CSVOutput struct {
 	filePath string
+	file     io.WriteCloser
 	writer   *csv.Writer
 	mutex    sync.Mutex
 }
 
 // NewCSVOutput creates a new CSV output writer.
 func NewCSVOutput(filePath string) (*CSVOutput, error) {
-	file, err := os.Create(filePath)
+	file, err := openFile(filePath)
 	if err != nil {
 		return nil, err
 	}
 	writer := csv.NewWriter(file)
-	writer.Write([]string{"Time", "Method", "URL", "Payload", "Status", "Content-Type", "Content-Length"})
+	writer.Write([]string{"Time", "Method", "URL", "Payload", "Status", "Content-Type", "Content-Length", "Words", "Lines", "TTFB (ms)", "Duration (ms)", "Anomaly", "Depth", "Extension", "Encoded", "Variant", "Cert Subject", "Cert SANs"})
 	return &CSVOutput{
 		filePath: filePath,
+		file:     file,
 		writer:   writer,
 	}, nil
 }
@@ -45,6 +44,17 @@ func (c *CSVOutput) Write(result Result) error {
 		fmt.Sprintf("%d", result.StatusCode),
 		strings.Join(result.Headers.Values("Content-Type"), ","),
 		fmt.Sprintf("%d", result.ContentLength),
+		fmt.Sprintf("%d", result.Words),
+		fmt.Sprintf("%d", result.Lines),
+		fmt.Sprintf("%d", result.TTFB.Milliseconds()),
+		fmt.Sprintf("%d", result.Duration.Milliseconds()),
+		fmt.Sprintf("%t", result.Anomaly),
+		fmt.Sprintf("%d", result.Depth),
+		result.Extension,
+		result.Encoded,
+		result.Variant,
+		result.CertSubject,
+		strings.Join(result.CertSANs, " "),
 	}
 	err := c.writer.Write(data)
 	if err != nil {
@@ -56,7 +66,14 @@ func (c *CSVOutput) Write(result Result) error {
 
 // Close closes the CSV output writer.
 func (c *CSVOutput) Close() error {
-	return nil
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		c.file.Close()
+		return err
+	}
+	return c.file.Close()
 }
 
 func init() {
@@ -67,14 +84,5 @@ func init() {
 
 // PrintSummary prints a summary of the results to stdout.
 func (c *CSVOutput) PrintSummary(summary Summary) {
-	fmt.Println()
-	color.Info.Tips("Summary:")
-	color.Info.Tips("  Total requests............: %d", summary.Total)
-	color.Info.Tips("  Successful requests.......: %d", summary.Successful)
-	color.Info.Tips("  Failed requests...........: %d", summary.Failed)
-	color.Info.Tips("  Percentage of successful..: %.2f%%", summary.SuccessRate())
-	color.Info.Tips("  Total time................: %v", summary.TotalTime().Truncate(time.Millisecond))
-	color.Info.Tips("  Average time..............: %v", summary.AverageTime().Truncate(time.Millisecond))
-	color.Info.Tips("  Fastest time..............: %v", summary.FastestTime().Truncate(time.Millisecond))
-	color.Info.Tips("  Slowest time..............: %v", summary.SlowestTime().Truncate(time.Millisecond))
+	PrintSummary(summary)
 }
@@ -7,38 +7,46 @@ import (
 	"sync"
 )
 
+// JSONOutput writes one JSON object per line.
 type JSONOutput struct {
 	OutputPath string
-	Stdout     bool
 	mutex      sync.Mutex
+	file       io.WriteCloser
 	encoder    *json.Encoder
 }
 
-// Write writes JSON-encoded data to the output destination.
-func (o *JSONOutput) Write(data interface{}) error {
+// NewJSONOutput creates a new JSON output writer.
+func NewJSONOutput(filePath string) (*JSONOutput, error) {
+	f, err := openFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	return &JSONOutput{
+		OutputPath: filePath,
+		file:       f,
+		encoder:    json.NewEncoder(f),
+	}, nil
+}
+
+// Write writes a JSON-encoded result to the output destination.
+func (o *JSONOutput) Write(result Result) error {
 	o.mutex.Lock()
 	defer o.mutex.Unlock()
 
-	if o.encoder == nil {
-		var output io.Writer
-		if o.Stdout {
-			output = io.MultiWriter(o.encoder, o.encoder)
-		} else {
-			f, err := openFile(o.OutputPath)
-			if err != nil {
-				return fmt.Errorf("failed to create output file: %w", err)
-			}
-			defer f.Close()
-
-			output = io.MultiWriter(f, o.encoder)
-		}
-
-		o.encoder = json.NewEncoder(output)
-	}
-
-	if err := o.encoder.Encode(data); err != nil {
+	if err := o.encoder.Encode(result); err != nil {
 		return fmt.Errorf("failed to write JSON data: %w", err)
 	}
 
 	return nil
 }
+
+// Close closes the JSON output writer.
+func (o *JSONOutput) Close() error {
+	return o.file.Close()
+}
+
+func init() {
+	RegisterOutput("json", func(filePath string) (Output, error) {
+		return NewJSONOutput(filePath)
+	})
+}
@@ -4,38 +4,81 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/projectdiscovery/gologger"
 )
 
-// Output defines an output instance to write output to
-type Output struct {
-	writer io.Writer
+// Output defines an output instance to write results to
+type Output interface {
+	// Write writes a single result. It is safe for concurrent use.
+	Write(result Result) error
+	// Close flushes and closes the output.
+	Close() error
 }
 
-// New creates a new instance of output
-func New(filename string) (*Output, error) {
-	var writer io.Writer
-	if filename == "" {
-		writer = os.Stdout
-	} else {
-		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
-		if err != nil {
-			gologger.Error().Msgf("Could not create output file: %s\n", err)
-			return nil, err
+// outputs holds the registered output formats
+var outputs = make(map[string]func(filePath string) (Output, error))
+
+// RegisterOutput registers an output format under the given name
+func RegisterOutput(name string, newOutput func(filePath string) (Output, error)) {
+	outputs[name] = newOutput
+}
+
+// New creates a new instance of output in the given format
+func New(format, filePath string) (Output, error) {
+	newOutput, ok := outputs[strings.ToLower(format)]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q (available: %s)", format, strings.Join(Formats(), ", "))
+	}
+	return newOutput(filePath)
+}
+
+// Formats returns the names of the registered output formats
+func Formats() []string {
+	var names []string
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteResults writes all results to the output file in the given format
+func WriteResults(format string, results []Result, filePath string) error {
+	out, err := New(format, filePath)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if err := out.Write(result); err != nil {
+			out.Close()
+			return err
 		}
-		writer = f
+	}
+	return out.Close()
+}
+
+// openFile opens the output file, or stdout if no file is given
+func openFile(filename string) (io.WriteCloser, error) {
+	if filename == "" {
+		return nopCloser{os.Stdout}, nil
 	}
 
-	return &Output{writer: writer}, nil
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		gologger.Error().Msgf("Could not create output file: %s\n", err)
+		return nil, err
+	}
+	return f, nil
 }
 
-// Println prints a string with a new line character
-func (o *Output) Println(str string) {
-	fmt.Fprintln(o.writer, str)
+// nopCloser keeps stdout open when an output is closed
+type nopCloser struct {
+	io.Writer
 }
 
-// Printf prints a formatted string
-func (o *Output) Printf(format string, a ...interface{}) {
-	fmt.Fprintf(o.writer, format, a...)
+func (nopCloser) Close() error {
+	return nil
 }
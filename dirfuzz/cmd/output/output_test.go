@@ -0,0 +1,158 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testResult(i int) Result {
+	return Result{
+		Method:        "GET",
+		URL:           fmt.Sprintf("http://h/%d", i),
+		Payload:       fmt.Sprint(i),
+		StatusCode:    200,
+		Headers:       http.Header{"Content-Type": {"text/html"}},
+		ContentLength: 42,
+		Words:         7,
+		Lines:         3,
+		Duration:      1500 * time.Millisecond,
+		Variant:       "swap",
+	}
+}
+
+// writeConcurrently writes n results to a new output of the format from
+// several goroutines and returns what the file holds.
+func writeConcurrently(t *testing.T, format string, n int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "out")
+	out, err := New(format, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := out.Write(testResult(i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestNew(t *testing.T) {
+	if got := strings.Join(Formats(), " "); got != "csv json text" {
+		t.Errorf("Formats() = %s", got)
+	}
+	if _, err := New("xml", ""); err == nil || !strings.Contains(err.Error(), "csv, json, text") {
+		t.Errorf("New(xml) error = %v", err)
+	}
+	out, err := New("JSON", filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+}
+
+func TestJSONOutput(t *testing.T) {
+	data := writeConcurrently(t, "json", 50)
+	lines := strings.Split(strings.TrimSpace(data), "\n")
+	if len(lines) != 50 {
+		t.Fatalf("wrote %d lines, want 50", len(lines))
+	}
+	for _, line := range lines {
+		var r Result
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		if r.StatusCode != 200 || r.Words != 7 || r.Duration != 1500*time.Millisecond || r.Headers.Get("Content-Type") != "text/html" {
+			t.Errorf("decoded %+v", r)
+		}
+	}
+}
+
+func TestCSVOutput(t *testing.T) {
+	records, err := csv.NewReader(strings.NewReader(writeConcurrently(t, "csv", 50))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 51 {
+		t.Fatalf("wrote %d records, want a header and 50", len(records))
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		if len(record) != len(header) {
+			t.Fatalf("record %q does not match the header %q", record, header)
+		}
+		fields := make(map[string]string)
+		for i, name := range header {
+			fields[name] = record[i]
+		}
+		if fields["Status"] != "200" || fields["Content-Type"] != "text/html" || fields["Duration (ms)"] != "1500" || fields["Variant"] != "swap" {
+			t.Errorf("record %v", fields)
+		}
+	}
+}
+
+func TestTextOutput(t *testing.T) {
+	data := writeConcurrently(t, "text", 50)
+	lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	if len(lines) != 50 {
+		t.Fatalf("wrote %d lines, want 50", len(lines))
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "[200]       42      7W     3L http://h/") || !strings.HasSuffix(line, " [swap]") {
+			t.Errorf("line %q", line)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "out")
+	out, err := New("text", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := testResult(1)
+	r.Anomaly = true
+	out.Write(r)
+	r.Variant = ""
+	out.Write(r)
+	out.Close()
+	written, _ := os.ReadFile(path)
+	want := "[200]       42      7W     3L http://h/1 [SLOW] [swap]\n" +
+		"[200]       42      7W     3L http://h/1 [SLOW]\n"
+	if string(written) != want {
+		t.Errorf("wrote %q, want %q", written, want)
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.json")
+	if err := WriteResults("json", []Result{testResult(1), testResult(2)}, path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if n := strings.Count(string(data), "\n"); n != 2 {
+		t.Errorf("wrote %d results, want 2", n)
+	}
+	if err := WriteResults("json", nil, filepath.Join(t.TempDir(), "missing", "out")); err == nil {
+		t.Error("wrote to a missing directory")
+	}
+}
@@ -0,0 +1,39 @@
+package output
+
+import (
+	"net/http"
+	"time"
+)
+
+// Result represents a single response that passed the filters.
+type Result struct {
+	Method  string `json:"method"`
+	URL     string `json:"url"`
+	Payload string `json:"payload"`
+	// Encoded is the payload as sent, if encoder chains changed it.
+	Encoded string `json:"encoded,omitempty"`
+	// Extension is the extension appended to the wordlist entry, if any.
+	Extension string `json:"extension,omitempty"`
+	// Variant is the type of the backup template that produced the
+	// payload, such as "swap" or "archive", if any.
+	Variant       string      `json:"variant,omitempty"`
+	StatusCode    int         `json:"status"`
+	Headers       http.Header `json:"headers"`
+	ContentLength int64       `json:"content_length"`
+	Words         int         `json:"words"`
+	Lines         int         `json:"lines"`
+	// TTFB is the time to the first response byte and Duration the time to
+	// the end of the body. Both are nanoseconds in JSON.
+	TTFB     time.Duration `json:"ttfb"`
+	Duration time.Duration `json:"duration"`
+	// Anomaly is set when the response was much slower than the average.
+	Anomaly bool `json:"anomaly,omitempty"`
+	// Depth is the number of directory levels below the target URL.
+	Depth int `json:"depth"`
+	// Directory is set when the response looks like a directory.
+	Directory bool `json:"directory"`
+	// CertSubject and CertSANs describe the certificate presented over
+	// TLS: its subject and its DNS, IP, email and URI alternative names.
+	CertSubject string   `json:"cert_subject,omitempty"`
+	CertSANs    []string `json:"cert_sans,omitempty"`
+}
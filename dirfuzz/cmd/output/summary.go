@@ -0,0 +1,139 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gookit/color"
+)
+
+// Summary collects statistics about a scan.
+type Summary struct {
+	Total      int
+	Successful int
+	Failed     int
+	// Anomalies counts responses that were much slower than the average.
+	Anomalies int
+	// Depths holds the statistics of each recursion depth.
+	Depths map[int]*DepthSummary
+
+	start   time.Time
+	end     time.Time
+	elapsed time.Duration
+	fastest time.Duration
+	slowest time.Duration
+}
+
+// DepthSummary collects statistics about a single recursion depth.
+type DepthSummary struct {
+	Bases       int
+	Requests    int
+	Hits        int
+	Directories int
+}
+
+// NewSummary creates an empty summary.
+func NewSummary() *Summary {
+	return &Summary{Depths: make(map[int]*DepthSummary)}
+}
+
+// Start marks the beginning of the scan.
+func (s *Summary) Start() {
+	s.start = time.Now()
+}
+
+// Finish marks the end of the scan.
+func (s *Summary) Finish() {
+	s.end = time.Now()
+}
+
+// Depth returns the statistics of the given depth, creating them if needed.
+func (s *Summary) Depth(depth int) *DepthSummary {
+	d, ok := s.Depths[depth]
+	if !ok {
+		d = &DepthSummary{}
+		s.Depths[depth] = d
+	}
+	return d
+}
+
+// Record adds a finished request to the summary.
+func (s *Summary) Record(depth int, elapsed time.Duration, err error) {
+	s.Total++
+	s.Depth(depth).Requests++
+	if err != nil {
+		s.Failed++
+		return
+	}
+	s.Successful++
+	s.elapsed += elapsed
+	if s.fastest == 0 || elapsed < s.fastest {
+		s.fastest = elapsed
+	}
+	if elapsed > s.slowest {
+		s.slowest = elapsed
+	}
+}
+
+// SuccessRate returns the percentage of requests that got a response.
+func (s Summary) SuccessRate() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Successful) / float64(s.Total) * 100
+}
+
+// TotalTime returns the wall clock time of the scan.
+func (s Summary) TotalTime() time.Duration {
+	if s.end.IsZero() {
+		return time.Since(s.start)
+	}
+	return s.end.Sub(s.start)
+}
+
+// AverageTime returns the average response time.
+func (s Summary) AverageTime() time.Duration {
+	if s.Successful == 0 {
+		return 0
+	}
+	return s.elapsed / time.Duration(s.Successful)
+}
+
+// FastestTime returns the fastest response time.
+func (s Summary) FastestTime() time.Duration {
+	return s.fastest
+}
+
+// SlowestTime returns the slowest response time.
+func (s Summary) SlowestTime() time.Duration {
+	return s.slowest
+}
+
+// PrintSummary prints a summary of the results to stdout.
+func PrintSummary(summary Summary) {
+	fmt.Println()
+	color.Info.Tips("Summary:")
+	color.Info.Tips("  Total requests............: %d", summary.Total)
+	color.Info.Tips("  Successful requests.......: %d", summary.Successful)
+	color.Info.Tips("  Failed requests...........: %d", summary.Failed)
+	color.Info.Tips("  Percentage of successful..: %.2f%%", summary.SuccessRate())
+	color.Info.Tips("  Total time................: %v", summary.TotalTime().Truncate(time.Millisecond))
+	color.Info.Tips("  Average time..............: %v", summary.AverageTime().Truncate(time.Millisecond))
+	color.Info.Tips("  Fastest time..............: %v", summary.FastestTime().Truncate(time.Millisecond))
+	color.Info.Tips("  Slowest time..............: %v", summary.SlowestTime().Truncate(time.Millisecond))
+	if summary.Anomalies > 0 {
+		color.Info.Tips("  Slow responses............: %d", summary.Anomalies)
+	}
+
+	depths := make([]int, 0, len(summary.Depths))
+	for depth := range summary.Depths {
+		depths = append(depths, depth)
+	}
+	sort.Ints(depths)
+	for _, depth := range depths {
+		d := summary.Depths[depth]
+		color.Info.Tips("  Depth %d...................: %d bases, %d requests, %d hits, %d directories",
+			depth, d.Bases, d.Requests, d.Hits, d.Directories)
+	}
+}
@@ -0,0 +1,61 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// TextOutput writes one human readable line per result
+type TextOutput struct {
+	writer io.WriteCloser
+	mutex  sync.Mutex
+}
+
+// NewTextOutput creates a new instance of text output
+func NewTextOutput(filename string) (*TextOutput, error) {
+	writer, err := openFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &TextOutput{writer: writer}, nil
+}
+
+// Write writes a result as a single line, marked like on the console if it
+// is slow or a backup variant
+func (o *TextOutput) Write(result Result) error {
+	line := fmt.Sprintf("[%d] %8d %6dW %5dL %s", result.StatusCode, result.ContentLength, result.Words, result.Lines, result.URL)
+	if result.Anomaly {
+		line += " [SLOW]"
+	}
+	if result.Variant != "" {
+		line += " [" + result.Variant + "]"
+	}
+	o.Println(line)
+	return nil
+}
+
+// Println prints a string with a new line character
+func (o *TextOutput) Println(str string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	fmt.Fprintln(o.writer, str)
+}
+
+// Printf prints a formatted string
+func (o *TextOutput) Printf(format string, a ...interface{}) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	fmt.Fprintf(o.writer, format, a...)
+}
+
+// Close closes the text output
+func (o *TextOutput) Close() error {
+	return o.writer.Close()
+}
+
+func init() {
+	RegisterOutput("text", func(filePath string) (Output, error) {
+		return NewTextOutput(filePath)
+	})
+}
@@ -0,0 +1,124 @@
+// Package wordlist provides the curated wordlists compiled into dirfuzz.
+// The first line of each list is a "# description" comment.
+package wordlist
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Prefix selects a builtin list in place of a wordlist path, as in
+// "builtin:common".
+const Prefix = "builtin:"
+
+// Default is the list used when no wordlist is given.
+const Default = "common"
+
+//go:embed *.txt
+var lists embed.FS
+
+// List describes a builtin wordlist.
+type List struct {
+	Name        string
+	Description string
+	Entries     int
+	Size        int64
+}
+
+// Names returns the names of the builtin lists in sorted order.
+func Names() []string {
+	files, _ := fs.Glob(lists, "*.txt")
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(file, ".txt"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lists describes all builtin lists.
+func Lists() []List {
+	var all []List
+	for _, name := range Names() {
+		l, err := Describe(name)
+		if err == nil {
+			all = append(all, l)
+		}
+	}
+	return all
+}
+
+// Describe describes the builtin list with the given name.
+func Describe(name string) (List, error) {
+	data, err := read(name)
+	if err != nil {
+		return List{}, err
+	}
+
+	l := List{Name: name, Size: int64(len(data))}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			if l.Description == "" {
+				l.Description = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			}
+		default:
+			l.Entries++
+		}
+	}
+	return l, nil
+}
+
+// Open opens the builtin list with the given name.
+func Open(name string) (io.ReadCloser, error) {
+	f, err := lists.Open(file(name))
+	if err != nil {
+		return nil, unknown(name)
+	}
+	return f, nil
+}
+
+// Export writes the builtin list with the given name to w.
+func Export(name string, w io.Writer) error {
+	data, err := read(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// IsBuiltin reports whether a wordlist path selects a builtin list, and
+// returns the name of the list.
+func IsBuiltin(p string) (string, bool) {
+	if !strings.HasPrefix(p, Prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(p, Prefix), true
+}
+
+func read(name string) ([]byte, error) {
+	data, err := lists.ReadFile(file(name))
+	if err != nil {
+		return nil, unknown(name)
+	}
+	return data, nil
+}
+
+func unknown(name string) error {
+	return fmt.Errorf("unknown builtin wordlist %q (want one of %s)", name, strings.Join(Names(), ", "))
+}
+
+func file(name string) string {
+	return path.Clean(name) + ".txt"
+}
@@ -0,0 +1,104 @@
+package wordlist
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLists(t *testing.T) {
+	names := Names()
+	if len(names) == 0 {
+		t.Fatal("no builtin lists")
+	}
+	found := false
+	for _, name := range names {
+		found = found || name == Default
+	}
+	if !found {
+		t.Errorf("the default list %q is not among %v", Default, names)
+	}
+
+	for _, l := range Lists() {
+		if l.Description == "" || l.Entries == 0 || l.Size == 0 {
+			t.Errorf("list %+v lacks a description or entries", l)
+		}
+
+		rc, err := Open(l.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[string]bool)
+		scanner := bufio.NewScanner(rc)
+		for i := 1; scanner.Scan(); i++ {
+			line := scanner.Text()
+			if i == 1 && !strings.HasPrefix(line, "# ") {
+				t.Errorf("%s starts with %q, not a description", l.Name, line)
+			}
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if strings.TrimSpace(line) != line || strings.ContainsAny(line, " \t\r") {
+				t.Errorf("%s:%d: entry %q has white space", l.Name, i, line)
+			}
+			if seen[line] {
+				t.Errorf("%s:%d: duplicate entry %q", l.Name, i, line)
+			}
+			seen[line] = true
+		}
+		rc.Close()
+		if len(seen) != l.Entries {
+			t.Errorf("%s has %d entries, described as %d", l.Name, len(seen), l.Entries)
+		}
+	}
+}
+
+func TestExportMatchesOpen(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(Default, &buf); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := Open(Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(rc)
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("Export and Open differ")
+	}
+}
+
+func TestUnknownList(t *testing.T) {
+	for _, name := range []string{"missing", "../wordlist", "common.txt", ""} {
+		if _, err := Open(name); err == nil || !strings.Contains(err.Error(), Default) {
+			t.Errorf("Open(%q) error = %v", name, err)
+		}
+		if _, err := Describe(name); err == nil {
+			t.Errorf("Describe(%q) succeeded", name)
+		}
+		if err := Export(name, io.Discard); err == nil {
+			t.Errorf("Export(%q) succeeded", name)
+		}
+	}
+}
+
+func TestIsBuiltin(t *testing.T) {
+	tests := []struct {
+		path, name string
+		ok         bool
+	}{
+		{"builtin:common", "common", true},
+		{"builtin:", "", true},
+		{"common", "", false},
+		{"./builtin:common", "", false},
+	}
+	for _, tt := range tests {
+		name, ok := IsBuiltin(tt.path)
+		if name != tt.name || ok != tt.ok {
+			t.Errorf("IsBuiltin(%q) = %q, %v, want %q, %v", tt.path, name, ok, tt.name, tt.ok)
+		}
+	}
+}